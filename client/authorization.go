@@ -19,7 +19,7 @@ import (
 // NewAnonymousClient returns a client capable of only returning data using
 // public endpoints. Any authenticated calls through this client will fail.
 func NewAnonymousClient(serverName string) *mastodon.Client {
-	return mastodon.NewClient(&mastodon.Config{
+	return NewClient(&mastodon.Config{
 		Server: serverURL(serverName),
 	})
 }
@@ -90,7 +90,7 @@ func RegisterNewClient(serverName string) (*mastodon.Client, error) {
 	}
 
 	// Create mastodon client
-	client := mastodon.NewClient(&mastodon.Config{
+	client := NewClient(&mastodon.Config{
 		Server:       serverURL(serverName),
 		ClientID:     app.ClientID,
 		ClientSecret: app.ClientSecret,
@@ -9,22 +9,77 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type TagTimeline func(tag string) ([]*mastodon.Status, error)
+// TagTimeline returns a page of statuses for a tag. If pg is non-nil it selects
+// the page to return, and is updated with the pagination of the next page.
+type TagTimeline func(tag string, pg *mastodon.Pagination) ([]*mastodon.Status, error)
 
 // ServerFeed returns a TagTimeline using the provided client.
 func ServerFeed(ctx context.Context, client *mastodon.Client) TagTimeline {
-	return func(tag string) ([]*mastodon.Status, error) {
-		log.Debugf("fetching timeline for tag: '%v'", tag)
-		return client.GetTimelineHashtag(ctx, tag, false, nil)
+	return func(tag string, pg *mastodon.Pagination) ([]*mastodon.Status, error) {
+		log.Debugf("fetching timeline for tag: '%v' (%s)", tag, describePage(pg))
+		return client.GetTimelineHashtag(ctx, tag, false, pg)
 	}
 }
 
 // FileFeed returns a TagTimeline using the provided filename source
 func FileFeed(filename string) TagTimeline {
-	return func(tag string) ([]*mastodon.Status, error) {
+	return func(tag string, pg *mastodon.Pagination) ([]*mastodon.Status, error) {
 		f, _ := os.Open(filename)
 		data := []*mastodon.Status{}
 		err := json.NewDecoder(f).Decode(&data)
+
+		if pg != nil {
+			// a file has exactly one page
+			*pg = mastodon.Pagination{}
+		}
 		return data, err
 	}
 }
+
+// NewerID reports whether status ID a is more recent than b. Mastodon IDs
+// are numeric strings which sort by length first, then lexically.
+func NewerID(a, b mastodon.ID) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// LatestID returns the most recent status ID found in items, or the
+// provided ID if none of the items are more recent.
+func LatestID(id mastodon.ID, items []*mastodon.Status) mastodon.ID {
+	for _, item := range items {
+		if NewerID(item.ID, id) {
+			id = item.ID
+		}
+	}
+	return id
+}
+
+// OldestID returns the least recent status ID found in items, or an empty
+// ID if there are no items.
+func OldestID(items []*mastodon.Status) mastodon.ID {
+	var id mastodon.ID
+
+	for _, item := range items {
+		if id == "" || NewerID(id, item.ID) {
+			id = item.ID
+		}
+	}
+	return id
+}
+
+func describePage(pg *mastodon.Pagination) string {
+	switch {
+	case pg == nil:
+		return "latest"
+	case pg.MinID != "":
+		return "min_id=" + string(pg.MinID)
+	case pg.MaxID != "":
+		return "max_id=" + string(pg.MaxID)
+	case pg.SinceID != "":
+		return "since_id=" + string(pg.SinceID)
+	default:
+		return "latest"
+	}
+}
@@ -27,8 +27,89 @@ func TestFetchItems(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			items, err := FileFeed(tc.input)("ignore_")
+			items, err := FileFeed(tc.input)("ignore_", nil)
 			tc.assertFunc(t, items, err)
 		})
 	}
 }
+
+func TestLatestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		current  mastodon.ID
+		input    []mastodon.ID
+		expected mastodon.ID
+	}{
+		{
+			name:     "no items",
+			current:  "109286874256020359",
+			expected: "109286874256020359",
+		},
+		{
+			name:     "no cursor",
+			input:    []mastodon.ID{"109263727273144392", "109286874256020359"},
+			expected: "109286874256020359",
+		},
+		{
+			name:     "older items",
+			current:  "109286874256020359",
+			input:    []mastodon.ID{"109263727273144392"},
+			expected: "109286874256020359",
+		},
+		{
+			name:     "longer id is newer",
+			current:  "99999999",
+			input:    []mastodon.ID{"100000000"},
+			expected: "100000000",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items := []*mastodon.Status{}
+			for _, id := range tc.input {
+				items = append(items, &mastodon.Status{ID: id})
+			}
+
+			actual := LatestID(tc.current, items)
+			if actual != tc.expected {
+				t.Errorf("expected ID to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOldestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []mastodon.ID
+		expected mastodon.ID
+	}{
+		{
+			name:     "no items",
+			expected: "",
+		},
+		{
+			name:     "newest first",
+			input:    []mastodon.ID{"109286874256020359", "109263727273144392"},
+			expected: "109263727273144392",
+		},
+		{
+			name:     "shorter id is older",
+			input:    []mastodon.ID{"100000000", "99999999"},
+			expected: "99999999",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items := []*mastodon.Status{}
+			for _, id := range tc.input {
+				items = append(items, &mastodon.Status{ID: id})
+			}
+
+			actual := OldestID(items)
+			if actual != tc.expected {
+				t.Errorf("expected ID to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
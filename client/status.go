@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// ErrStatusNotFound is returned when a status no longer exists on a server,
+// or is no longer visible to this client (e.g. deleted by its author).
+var ErrStatusNotFound = errors.New("status not found")
+
+// Status is a mastodon.Status including the time it was last edited, which
+// is not decoded by go-mastodon.
+type Status struct {
+	mastodon.Status
+	EditedAt *time.Time `json:"edited_at"`
+}
+
+// GetStatus fetches a status by its ID on the client's server. It returns
+// ErrStatusNotFound if the server reports the status as gone.
+func GetStatus(ctx context.Context, cl *mastodon.Client, id mastodon.ID) (*Status, error) {
+	u, err := url.Parse(cl.Config.Server)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, "/api/v1/statuses", url.PathEscape(string(id)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cl.Config.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cl.Config.AccessToken)
+	}
+	if cl.UserAgent != "" {
+		req.Header.Set("User-Agent", cl.UserAgent)
+	}
+
+	resp, err := cl.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, ErrStatusNotFound
+	case resp.StatusCode >= 400:
+		return nil, fmt.Errorf("bad request: %v", resp.Status)
+	}
+
+	var status Status
+
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
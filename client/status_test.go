@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestGetStatus(t *testing.T) {
+	edited := time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		status         int
+		body           string
+		expectErr      error
+		expectEditedAt *time.Time
+	}{
+		{
+			name:   "unedited",
+			status: 200,
+			body:   `{"id":"7","uri":"https://a.example/7","content":"<p>hi</p>","edited_at":null}`,
+		},
+		{
+			name:           "edited",
+			status:         200,
+			body:           `{"id":"7","uri":"https://a.example/7","content":"<p>hi</p>","edited_at":"2023-07-01T12:30:00.000Z"}`,
+			expectEditedAt: &edited,
+		},
+		{
+			name:      "deleted",
+			status:    404,
+			body:      `{"error":"Record not found"}`,
+			expectErr: ErrStatusNotFound,
+		},
+		{
+			name:      "gone",
+			status:    410,
+			expectErr: ErrStatusNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/statuses/7" {
+					t.Errorf("unexpected request: %s\n", r.URL)
+				}
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer ts.Close()
+
+			status, err := GetStatus(context.Background(), mastodon.NewClient(&mastodon.Config{Server: ts.URL}), "7")
+
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error to match (%v / %v)\n", tc.expectErr, err)
+			}
+
+			if tc.expectErr != nil {
+				return
+			}
+
+			if status.ID != "7" || status.Content != "<p>hi</p>" {
+				t.Errorf("expected status to be decoded, was: %+v\n", status.Status)
+			}
+
+			switch {
+			case tc.expectEditedAt == nil && status.EditedAt != nil:
+				t.Errorf("expected no edit, was: %v\n", status.EditedAt)
+			case tc.expectEditedAt != nil && (status.EditedAt == nil || !status.EditedAt.Equal(*tc.expectEditedAt)):
+				t.Errorf("expected edited_at to match (%v / %v)\n", tc.expectEditedAt, status.EditedAt)
+			}
+		})
+	}
+}
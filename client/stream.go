@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrStreamingUnavailable is returned when a server does not allow this
+// client to use the streaming API (e.g. anonymous streaming is disabled).
+var ErrStreamingUnavailable = errors.New("streaming not available")
+
+// HashtagStream subscribes to the 'hashtag' (or 'hashtag:local') stream
+// of a server using server-sent events.
+type HashtagStream struct {
+	Client *mastodon.Client
+	Tag    string
+	Local  bool
+
+	// OnConnect is called each time the stream is (re)connected, before any
+	// statuses are delivered. It may be used to catch up on missed posts.
+	OnConnect func()
+
+	// OnUpdate is called for each status received.
+	OnUpdate func(*mastodon.Status)
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Run streams statuses until the context is canceled, reconnecting with
+// backoff when the connection drops. It returns ErrStreamingUnavailable if
+// the server refuses to stream, or the context error once canceled.
+func (s *HashtagStream) Run(ctx context.Context) error {
+	minBackoff, maxBackoff := s.MinBackoff, s.MaxBackoff
+
+	if minBackoff == 0 {
+		minBackoff = time.Second
+	}
+	if maxBackoff == 0 {
+		maxBackoff = 5 * time.Minute
+	}
+
+	backoff := minBackoff
+
+	for {
+		connected := time.Now()
+		err := s.stream(ctx)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, ErrStreamingUnavailable) {
+			return err
+		}
+
+		if time.Since(connected) > maxBackoff {
+			// connection was healthy for a while
+			backoff = minBackoff
+		}
+
+		log.Debugf("stream for '%s' on %s disconnected (%v), reconnecting in %s", s.Tag, s.Client.Config.Server, err, backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// stream reads a single connection until it fails or is closed.
+func (s *HashtagStream) stream(ctx context.Context) error {
+	req, err := s.newRequest(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrStreamingUnavailable, resp.Status)
+	default:
+		return fmt.Errorf("streaming failed: %s", resp.Status)
+	}
+
+	log.Infof("streaming '%s' from %s", s.Tag, s.Client.Config.Server)
+
+	if s.OnConnect != nil {
+		s.OnConnect()
+	}
+
+	return readEvents(bufio.NewScanner(resp.Body), s.OnUpdate)
+}
+
+func (s *HashtagStream) newRequest(ctx context.Context) (*http.Request, error) {
+	u, err := url.Parse(s.Client.Config.Server)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stream := "hashtag"
+	if s.Local {
+		stream += "/local"
+	}
+
+	u.Path = path.Join(u.Path, "/api/v1/streaming", stream)
+	u.RawQuery = url.Values{"tag": []string{s.Tag}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	if s.Client.Config.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Client.Config.AccessToken)
+	}
+
+	return req, nil
+}
+
+// readEvents parses server-sent events from the scanner, passing any
+// 'update' events to onUpdate. It returns when the stream ends.
+func readEvents(scanner *bufio.Scanner, onUpdate func(*mastodon.Status)) error {
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var (
+		event string
+		data  strings.Builder
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// blank line dispatches the event
+			if event == "update" && data.Len() > 0 {
+				var status mastodon.Status
+
+				if err := json.Unmarshal([]byte(data.String()), &status); err != nil {
+					log.Debugf("invalid status in stream: %v", err)
+				} else if onUpdate != nil {
+					onUpdate(&status)
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// comment (heartbeat)
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("stream closed by server")
+}
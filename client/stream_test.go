@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestHashtagStream(t *testing.T) {
+	testCases := []struct {
+		name      string
+		status    int
+		body      string
+		expectErr error
+		expectIDs []mastodon.ID
+	}{
+		{
+			name:   "updates",
+			status: 200,
+			body: ":thump\n\n" +
+				"event: update\ndata: {\"id\":\"1\",\"uri\":\"https://a.example/1\"}\n\n" +
+				"event: delete\ndata: 7\n\n" +
+				"event: update\ndata: {\"id\":\"2\",\"uri\":\"https://a.example/2\"}\n\n",
+			expectErr: context.Canceled,
+			expectIDs: []mastodon.ID{"1", "2"},
+		},
+		{
+			name:      "anonymous streaming disabled",
+			status:    401,
+			expectErr: ErrStreamingUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/streaming/hashtag" || r.URL.Query().Get("tag") != "outage" {
+					t.Errorf("unexpected request: %s\n", r.URL)
+				}
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer ts.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var ids []mastodon.ID
+
+			s := &HashtagStream{
+				Client:     mastodon.NewClient(&mastodon.Config{Server: ts.URL}),
+				Tag:        "outage",
+				MinBackoff: time.Hour,
+				OnUpdate: func(st *mastodon.Status) {
+					ids = append(ids, st.ID)
+					if len(ids) == len(tc.expectIDs) {
+						cancel()
+					}
+				},
+			}
+
+			err := s.Run(ctx)
+
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("expected error to match (%v / %v)\n", tc.expectErr, err)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expectIDs) {
+				t.Errorf("expected IDs to match (%v / %v)\n", tc.expectIDs, ids)
+			}
+		})
+	}
+}
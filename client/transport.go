@@ -0,0 +1,319 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTransport is shared by all clients created by this package, so that
+// rate limits are tracked per host regardless of how many clients use it.
+var DefaultTransport = NewTransport(http.DefaultTransport)
+
+// RateLimit is the request budget last reported by a server.
+type RateLimit struct {
+	Host      string    `json:"host"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Exhausted reports whether no requests remain before the budget resets.
+func (rl RateLimit) Exhausted(now time.Time) bool {
+	return rl.Remaining <= 0 && now.Before(rl.Reset)
+}
+
+// Low reports whether the remaining budget has dropped below the fraction
+// of the limit, and will not reset before now.
+func (rl RateLimit) Low(now time.Time, fraction float64) bool {
+	return float64(rl.Remaining) < float64(rl.Limit)*fraction && now.Before(rl.Reset)
+}
+
+// RateLimitError is returned when a server continues to throttle requests
+// after all retries have been used.
+type RateLimitError struct {
+	Host  string
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s until %s", e.Host, e.Reset.Format(time.RFC3339))
+}
+
+// ErrorCount is the number of error responses returned by a host with a
+// status code, including responses which were retried.
+type ErrorCount struct {
+	Host  string `json:"host"`
+	Code  int    `json:"code"`
+	Count int    `json:"count"`
+}
+
+// Transport is an http.RoundTripper which tracks the rate limit headers
+// returned by Mastodon servers. When a host's budget is exhausted, requests
+// wait until it resets. Responses with status 429, or 5xx for idempotent
+// requests, are retried with jittered exponential backoff.
+type Transport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu     sync.Mutex
+	limits map[string]RateLimit
+	errors map[ErrorCount]int
+}
+
+// NewTransport returns a Transport wrapping base.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:       base,
+		MaxRetries: 4,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		limits:     map[string]RateLimit{},
+		errors:     map[ErrorCount]int{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	if err := t.waitForBudget(req); err != nil {
+		return nil, err
+	}
+
+	// retries send a copy of the request, as RoundTrip must not modify it
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.Base.RoundTrip(attemptReq)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rl := t.update(host, resp.Header)
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			t.recordError(host, resp.StatusCode)
+		}
+
+		if !retryable(req, resp.StatusCode) {
+			return resp, nil
+		}
+
+		if attempt >= t.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				resp.Body.Close()
+				return nil, &RateLimitError{Host: host, Reset: rl.Reset}
+			}
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if wait := retryAfter(resp.Header, rl, time.Now()); wait > delay {
+				delay = wait
+			}
+		}
+
+		resp.Body.Close()
+		log.Debugf("%s returned %s, retrying in %s", host, resp.Status, delay.Round(time.Millisecond))
+
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+
+		attemptReq = req.Clone(req.Context())
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+	}
+}
+
+// Budget returns the rate limit last reported by host, if any.
+func (t *Transport) Budget(host string) (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rl, ok := t.limits[host]
+	return rl, ok
+}
+
+// Budgets returns the rate limits last reported by all hosts.
+func (t *Transport) Budgets() []RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := make([]RateLimit, 0, len(t.limits))
+	for _, rl := range t.limits {
+		results = append(results, rl)
+	}
+	return results
+}
+
+// Errors returns the number of error responses returned by each host,
+// by status code, ordered by host and code.
+func (t *Transport) Errors() []ErrorCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := make([]ErrorCount, 0, len(t.errors))
+	for key, n := range t.errors {
+		key.Count = n
+		results = append(results, key)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return results[i].Host < results[j].Host
+		}
+		return results[i].Code < results[j].Code
+	})
+	return results
+}
+
+func (t *Transport) recordError(host string, code int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.errors[ErrorCount{Host: host, Code: code}]++
+}
+
+// waitForBudget blocks until the budget for the request's host resets, if
+// it has been exhausted.
+func (t *Transport) waitForBudget(req *http.Request) error {
+	rl, ok := t.Budget(req.URL.Host)
+
+	if !ok || !rl.Exhausted(time.Now()) {
+		return nil
+	}
+
+	wait := time.Until(rl.Reset)
+	log.Infof("rate limit reached for %s, waiting %s", rl.Host, wait.Round(time.Second))
+
+	return sleep(req, wait)
+}
+
+// update records the rate limit headers in resp for host, and returns the
+// current budget.
+func (t *Transport) update(host string, h http.Header) RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rl := t.limits[host]
+	rl.Host = host
+
+	limit, errLimit := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+
+	if errLimit != nil || errRemaining != nil {
+		return rl
+	}
+
+	rl.Limit = limit
+	rl.Remaining = remaining
+
+	if reset, err := time.Parse(time.RFC3339Nano, h.Get("X-RateLimit-Reset")); err == nil {
+		rl.Reset = reset
+	}
+
+	t.limits[host] = rl
+	return rl
+}
+
+// backoff returns a jittered delay which doubles with each attempt.
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.MinBackoff << attempt
+
+	if d > t.MaxBackoff || d <= 0 {
+		d = t.MaxBackoff
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// NewClient returns a mastodon client using the DefaultTransport.
+func NewClient(config *mastodon.Config) *mastodon.Client {
+	c := mastodon.NewClient(config)
+	c.Transport = DefaultTransport
+	return c
+}
+
+// Budget returns the rate limit last reported to the client by its server,
+// if the client uses a Transport.
+func Budget(c *mastodon.Client) (RateLimit, bool) {
+	t, ok := c.Transport.(*Transport)
+
+	if !ok {
+		return RateLimit{}, false
+	}
+
+	u, err := url.Parse(c.Config.Server)
+
+	if err != nil {
+		return RateLimit{}, false
+	}
+
+	return t.Budget(u.Host)
+}
+
+// retryable reports whether a request may be retried after a response with
+// the status. Throttled requests were not processed, so are always retried,
+// while server errors are only retried for idempotent methods.
+func retryable(req *http.Request, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+
+	if status < http.StatusInternalServerError || status > 599 {
+		return false
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by a throttled response, either
+// through the Retry-After header, or the reset time of the rate limit.
+func retryAfter(h http.Header, rl RateLimit, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+
+	if rl.Reset.After(now) {
+		return rl.Reset.Sub(now)
+	}
+	return 0
+}
+
+// sleep waits for d, or returns an error if the request is canceled.
+func sleep(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
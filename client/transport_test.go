@@ -0,0 +1,215 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	reset := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		name       string
+		method     string
+		statuses   []int
+		expectErr  bool
+		expectCode int
+		expectHits int
+		expectErrs int
+	}{
+		{
+			name:       "success",
+			statuses:   []int{200},
+			expectCode: 200,
+			expectHits: 1,
+		},
+		{
+			name:       "retries server errors",
+			statuses:   []int{503, 502, 200},
+			expectCode: 200,
+			expectHits: 3,
+			expectErrs: 2,
+		},
+		{
+			name:       "retries internal server errors",
+			statuses:   []int{500, 200},
+			expectCode: 200,
+			expectHits: 2,
+			expectErrs: 1,
+		},
+		{
+			name:       "does not retry server errors of a POST",
+			method:     http.MethodPost,
+			statuses:   []int{500, 200},
+			expectCode: 500,
+			expectHits: 1,
+			expectErrs: 1,
+		},
+		{
+			name:       "gives up on server errors",
+			statuses:   []int{503, 503, 503},
+			expectCode: 503,
+			expectHits: 3,
+			expectErrs: 3,
+		},
+		{
+			name:       "gives up when throttled",
+			statuses:   []int{429, 429, 429},
+			expectErr:  true,
+			expectHits: 3,
+			expectErrs: 3,
+		},
+		{
+			name:       "does not retry client errors",
+			statuses:   []int{404, 200},
+			expectCode: 404,
+			expectHits: 1,
+			expectErrs: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hits := 0
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Limit", "300")
+				w.Header().Set("X-RateLimit-Remaining", "299")
+				w.Header().Set("X-RateLimit-Reset", reset.Format(time.RFC3339Nano))
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tc.statuses[hits])
+				hits++
+			}))
+			defer ts.Close()
+
+			tr := NewTransport(http.DefaultTransport)
+			tr.MaxRetries = 2
+			tr.MinBackoff = time.Millisecond
+			tr.MaxBackoff = time.Millisecond
+
+			req, err := http.NewRequest(tc.method, ts.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := (&http.Client{Transport: tr}).Do(req)
+
+			var rateErr *RateLimitError
+			if tc.expectErr && !errors.As(err, &rateErr) {
+				t.Fatalf("expected rate limit error, was: %v\n", err)
+			}
+			if !tc.expectErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				if resp.StatusCode != tc.expectCode {
+					t.Errorf("expected status to match (%v / %v)\n", tc.expectCode, resp.StatusCode)
+				}
+			}
+			if hits != tc.expectHits {
+				t.Errorf("expected requests to match (%v / %v)\n", tc.expectHits, hits)
+			}
+
+			u, _ := url.Parse(ts.URL)
+			rl, ok := tr.Budget(u.Host)
+
+			if !ok || rl.Limit != 300 || rl.Remaining != 299 || !rl.Reset.Equal(reset) {
+				t.Errorf("unexpected budget: %+v\n", rl)
+			}
+
+			var errs int
+			for _, ec := range tr.Errors() {
+				if ec.Host != u.Host || ec.Code < 400 {
+					t.Errorf("unexpected error count: %+v\n", ec)
+				}
+				errs += ec.Count
+			}
+
+			if errs != tc.expectErrs {
+				t.Errorf("expected error responses to match (%v / %v)\n", tc.expectErrs, errs)
+			}
+		})
+	}
+}
+
+func TestTransportRetriesBody(t *testing.T) {
+	var bodies []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	tr := NewTransport(http.DefaultTransport)
+	tr.MinBackoff = time.Millisecond
+	tr.MaxBackoff = time.Millisecond
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL, strings.NewReader("status=hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	expected := []string{"status=hello", "status=hello"}
+
+	if fmt.Sprint(bodies) != fmt.Sprint(expected) {
+		t.Errorf("expected request bodies to match (%v / %v)\n", expected, bodies)
+	}
+
+	if req.Body != body {
+		t.Errorf("expected the request's body to be unchanged\n")
+	}
+}
+
+func TestRateLimitLow(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name     string
+		input    RateLimit
+		expected bool
+	}{
+		{
+			name:     "plenty remaining",
+			input:    RateLimit{Limit: 300, Remaining: 200, Reset: now.Add(time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "few remaining",
+			input:    RateLimit{Limit: 300, Remaining: 10, Reset: now.Add(time.Minute)},
+			expected: true,
+		},
+		{
+			name:     "budget already reset",
+			input:    RateLimit{Limit: 300, Remaining: 10, Reset: now.Add(-time.Minute)},
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := tc.input.Low(now, 0.1)
+			if actual != tc.expected {
+				t.Errorf("expected Low to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -5,11 +5,12 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/ivan3bx/proma/client"
 	"github.com/ivan3bx/proma/stats"
@@ -22,6 +23,7 @@ import (
 
 var tagNames []string
 var webServer bool
+var streaming bool
 
 // collectCmd represents the collect command
 var collectCmd = &cobra.Command{
@@ -31,11 +33,40 @@ var collectCmd = &cobra.Command{
 
 Example:
 
-Collect posts tagged with '#outage', every 2 minutes on 'mastodon.social'
+Collect posts tagged with '#outage', every 2 minutes on 'mastodon.social', until interrupted
 proma collect -t outage -i 2 -s mastodon.social
+
+Collect '#outage' every 30 seconds and '#flood' at the top of each hour
+proma collect -t outage,flood -i @hourly --tag-interval outage=30s
+
+Poll quiet tags as rarely as every 30 minutes, and busy tags as often as every minute
+proma collect -t outage,flood -i 1m..30m
+
+Stream posts tagged with '#outage' as they are published, until interrupted
+proma collect -t outage --stream -s mastodon.social
+
+Backfill posts tagged with '#outage' from the last 3 days before collecting
+proma collect -t outage --since 3d -d outage.db
+
+Collect the watches 'outages' and 'floods', each on its own schedule, until interrupted
+proma collect --watch outages,floods
+
+Recheck posts from the last 2 days every hour, removing those deleted by their authors
+proma collect -t outage -i 5m --recheck 1h --recheck-window 48h --purge-deleted -d outage.db
+
+Keep 90 days of posts, pruning hourly, and vacuum the database weekly
+proma collect -t outage -i 5m --max-age 90d --vacuum @weekly -d outage.db
+
+Serve the stats page over HTTPS on all interfaces, requiring a password
+proma collect -t outage --http --listen :8443 --self-signed --basic-auth admin:secret
 `,
 	PreRun: anonymousClientAllowed,
 	Run: func(cmd *cobra.Command, args []string) {
+		if names, _ := cmd.Flags().GetStringSlice("watch"); len(names) > 0 {
+			collectWatches(cmd, names)
+			return
+		}
+
 		var (
 			clients []*mastodon.Client
 			db      *sqlx.DB
@@ -52,13 +83,54 @@ proma collect -t outage -i 2 -s mastodon.social
 
 		c = stats.NewCollector(clients, db)
 
+		if workers, _ := cmd.Flags().GetInt("workers"); workers > 0 {
+			c.SetWorkers(workers)
+		}
+
+		watch, err := scheduleFlags(cmd)
+		cobra.CheckErr(err)
+
+		watch.Tags = tagNames
+		watch.Clients = clients
+
+		if watch.Schedule != nil {
+			c.SetSchedule(watch.Schedule)
+		}
+
+		cobra.CheckErr(setRecheck(cmd, c))
+		cobra.CheckErr(setRetention(cmd, c))
+
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			cutoff, err := stats.ParseSince(since, time.Now())
+			cobra.CheckErr(err)
+
+			_, err = c.Backfill(cmd.Context(), tagNames, cutoff)
+			cobra.CheckErr(err)
+		}
+
+		startCollector := func() {
+			if streaming {
+				local, _ := cmd.Flags().GetBool("local")
+				c.StartStream(cmd.Context(), tagNames, local)
+			} else {
+				c.StartWatches(cmd.Context(), []stats.Watch{watch})
+			}
+		}
+
+		// collect on a schedule when an interval or recheck is set
+		recheck, _ := cmd.Flags().GetString("recheck")
+		scheduled := watch.Schedule != nil || len(watch.TagSchedules) > 0 || len(watch.ServerSchedules) > 0 || recheck != ""
+
 		if webServer {
 			// start collector in the background
-			c.Start(cmd.Context(), tagNames)
+			startCollector()
 
 			// start web server
-			w = stats.NewServer(cmd.Context(), db)
-			w.Start()
+			opts, err := serverOptions(cmd)
+			cobra.CheckErr(err)
+
+			w = stats.NewServer(cmd.Context(), c, tagNames, opts)
+			cobra.CheckErr(w.Start())
 
 			waitForInterrupt(cmd.Context(), func() {
 				c.Stop()
@@ -68,93 +140,262 @@ proma collect -t outage -i 2 -s mastodon.social
 			})
 
 		} else {
-			// Collect data from any configured servers
-			c.Collect(cmd.Context(), tagNames)
+			if streaming || scheduled {
+				// Stream or collect data from any configured servers until interrupted
+				startCollector()
+				waitForInterrupt(cmd.Context(), c.Stop)
+			} else {
+				// Collect data from any configured servers
+				summary, err := c.Collect(cmd.Context(), tagNames)
+
+				if err != nil {
+					log.Errorf("%v", err)
+				} else {
+					for _, r := range summary.Failed() {
+						log.Warnf("skipped %s: %v", r.Server, r.Err)
+					}
+				}
+			}
 
 			// Generate and print a report
-			stats, err := c.Report(cmd.Context(), tagNames)
+			printReport(cmd, c, tagNames)
+		}
+	},
+}
 
-			if err != nil {
-				panic(err)
-			}
+func init() {
+	rootCmd.AddCommand(collectCmd)
+	collectCmd.Flags().StringP("database", "d", "", "database file, or postgres:// URL, to store results (default in-memory)")
+	collectCmd.Flags().StringSliceVarP(&tagNames, "tags", "t", []string{}, "tag names")
+	collectCmd.Flags().StringSlice("watch", []string{}, "collect watches saved by 'watch add', until interrupted")
+	addScheduleFlags(collectCmd)
+	collectCmd.Flags().Int("workers", 4, "number of servers to collect from concurrently")
+	collectCmd.Flags().String("since", "", "backfill posts since a date (2006-01-02) or duration (e.g. 36h, 7d)")
+	collectCmd.Flags().String("recheck", "", "interval between rechecks of collected posts for edits, deletions and engagement (e.g. 1h, @daily)")
+	collectCmd.Flags().Duration("recheck-window", 7*24*time.Hour, "recheck posts created within this duration (with --recheck)")
+	collectCmd.Flags().Bool("purge-deleted", false, "remove posts deleted by their authors, instead of keeping them without content")
+	collectCmd.Flags().String("prune", "", "interval between pruning posts beyond the retention limits (default @hourly with --max-age or --max-posts-per-tag)")
+	collectCmd.Flags().String("vacuum", "", "interval between vacuuming the database to reclaim space (e.g. @weekly)")
+	addRetentionFlags(collectCmd)
+	collectCmd.Flags().BoolVar(&streaming, "stream", false, "collect posts in real-time using the streaming API")
+	collectCmd.Flags().Bool("local", false, "stream only posts local to each server (with --stream)")
+	collectCmd.Flags().BoolVar(&webServer, "http", false, "display stats page (default http://localhost:8080/)")
+	addReportFlags(collectCmd, "2d")
+	addServerFlags(collectCmd)
+}
 
-			if len(stats) == 0 {
-				fmt.Fprintln(os.Stderr, "no results")
-				return
-			}
+// collectWatches runs the named watches until interrupted, using a collector
+// for each database. Watches served with --http must share a database.
+func collectWatches(cmd *cobra.Command, names []string) {
+	watches, err := findWatches(names)
+	cobra.CheckErr(err)
+
+	if streaming || len(tagNames) > 0 {
+		cobra.CheckErr(errors.New("--watch can not be combined with --stream or --tags"))
+	}
 
-			enc := json.NewEncoder(cmd.OutOrStdout())
-			enc.SetIndent("", "  ")
+	var (
+		databases  []string
+		byDatabase = map[string][]stats.Watch{}
+		watchTags  []string
+	)
+
+	for _, wc := range watches {
+		w, err := wc.watch()
+		cobra.CheckErr(err)
+
+		for _, s := range wc.Servers {
+			w.Clients = append(w.Clients, client.NewAnonymousClient(s))
+		}
+
+		if _, ok := byDatabase[wc.Database]; !ok {
+			databases = append(databases, wc.Database)
+		}
+
+		byDatabase[wc.Database] = append(byDatabase[wc.Database], w)
+		watchTags = append(watchTags, wc.Tags...)
+	}
+
+	if webServer && len(databases) > 1 {
+		cobra.CheckErr(errors.New("watches collected with --http must share a database"))
+	}
+
+	var cutoff time.Time
+
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		cutoff, err = stats.ParseSince(since, time.Now())
+		cobra.CheckErr(err)
+	}
+
+	// --interval sets the schedule of watches without an interval
+	defaults, err := scheduleFlags(cmd)
+	cobra.CheckErr(err)
+
+	var collectors []*stats.Collector
+
+	for _, dbName := range databases {
+		c := stats.NewCollector(nil, initDB(dbName))
+
+		if workers, _ := cmd.Flags().GetInt("workers"); workers > 0 {
+			c.SetWorkers(workers)
+		}
+
+		if defaults.Schedule != nil {
+			c.SetSchedule(defaults.Schedule)
+		}
 
-			if err := enc.Encode(stats); err != nil {
-				panic(err)
+		cobra.CheckErr(setRecheck(cmd, c))
+		cobra.CheckErr(setRetention(cmd, c))
+
+		if !cutoff.IsZero() {
+			for _, w := range byDatabase[dbName] {
+				_, err := c.BackfillWatch(cmd.Context(), w, cutoff)
+				cobra.CheckErr(err)
 			}
 		}
-	},
+
+		c.StartWatches(cmd.Context(), byDatabase[dbName])
+		collectors = append(collectors, c)
+	}
+
+	var w *stats.Server
+
+	if webServer {
+		opts, err := serverOptions(cmd)
+		cobra.CheckErr(err)
+
+		w = stats.NewServer(cmd.Context(), collectors[0], watchTags, opts)
+		cobra.CheckErr(w.Start())
+	}
+
+	waitForInterrupt(cmd.Context(), func() {
+		for _, c := range collectors {
+			c.Stop()
+		}
+		if w != nil {
+			w.Shutdown()
+		}
+	})
 }
 
-func init() {
-	rootCmd.AddCommand(collectCmd)
-	collectCmd.Flags().StringP("database", "d", "", "database file to store results (default in-memory)")
-	collectCmd.Flags().StringSliceVarP(&tagNames, "tags", "t", []string{}, "tag names")
-	collectCmd.Flags().BoolVar(&webServer, "http", false, "display stats page (http://localhost:8080/)")
+// addScheduleFlags registers the flags setting when tags are collected.
+func addScheduleFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringP("interval", "i", "", "minutes, duration (90s), adaptive range (1m..30m) or cron expression between runs (default 1m)")
+	f.StringToString("tag-interval", nil, "interval for individual tags (e.g. outage=30s)")
+	f.StringToString("server-interval", nil, "interval for individual servers (e.g. mastodon.social=5m)")
 }
 
-func initDB(dbName string) *sqlx.DB {
+// scheduleFlags returns a watch with the schedules set by flags registered
+// with addScheduleFlags.
+func scheduleFlags(cmd *cobra.Command) (stats.Watch, error) {
 	var (
-		db *sqlx.DB
+		w   stats.Watch
+		err error
 	)
 
-	if dbName != "" {
-		// returns DB if exists
-		if _, err := os.Stat(dbName); err == nil {
-			log.Debugf("using existing db: %s\n", dbName)
-			return sqlx.MustOpen("sqlite3", dbName)
-		}
-	} else {
-		// default to in-memory db
-		dbName = ":memory:"
-	}
-
-	log.Debugf("using database: %s\n", dbName)
-	db = sqlx.MustOpen("sqlite3", dbName)
-
-	schema := `
-		CREATE TABLE tags (
-			id INTEGER PRIMARY KEY,
-			name TEXT NOT NULL
-		);
-
-		CREATE UNIQUE INDEX idx_tags_name ON tags (name);
-
-		CREATE TABLE posts (
-			id INTEGER PRIMARY KEY,
-			post_id TEXT NOT NULL,
-			account_id TEXT NOT NULL,
-			server TEXT NOT NULL,
-			uri TEXT NOT NULL,
-			lang TEXT DEFAULT 'en' NOT NULL,
-			content_html TEXT,
-			content_text TEXT,
-			created_at TEXT
-		);
-
-		CREATE UNIQUE INDEX idx_posts_uri ON posts (uri);
-
-		CREATE TABLE posts_tags (
-			post_id INTEGER NOT NULL,
-			tag_id INTEGER NOT NULL,
-			PRIMARY KEY (post_id, tag_id)
-		);
-
-		-- index relies on sqlite3 FTS extension (go run .. --tags=fts5)
-		-- CREATE VIRTUAL TABLE content_index USING FTS5 (
-		--	post_id,
-		--	content
-		-- );
-	`
-
-	db.MustExec(schema)
+	if interval, _ := cmd.Flags().GetString("interval"); interval != "" {
+		if w.Schedule, err = stats.ParseSchedule(interval); err != nil {
+			return w, err
+		}
+	}
+
+	tagIntervals, _ := cmd.Flags().GetStringToString("tag-interval")
+
+	if w.TagSchedules, err = parseSchedules(tagIntervals); err != nil {
+		return w, err
+	}
+
+	serverIntervals, _ := cmd.Flags().GetStringToString("server-interval")
+	w.ServerSchedules, err = parseSchedules(serverIntervals)
+
+	return w, err
+}
+
+// setRecheck sets when the collector rechecks posts for edits, deletions
+// and engagement from the --recheck flags.
+func setRecheck(cmd *cobra.Command, c *stats.Collector) error {
+	purge, _ := cmd.Flags().GetBool("purge-deleted")
+	c.SetPurgeDeleted(purge)
+
+	interval, _ := cmd.Flags().GetString("recheck")
+
+	if interval == "" {
+		return nil
+	}
+
+	s, err := stats.ParseSchedule(interval)
+
+	if err != nil {
+		return fmt.Errorf("--recheck: %w", err)
+	}
+
+	window, _ := cmd.Flags().GetDuration("recheck-window")
+	c.SetRecheck(s, window)
+
+	return nil
+}
+
+// setRetention sets the retention limits of the collector, and when it
+// prunes and vacuums the database, from the flags registered with
+// addRetentionFlags and the --prune and --vacuum flags.
+func setRetention(cmd *cobra.Command, c *stats.Collector) error {
+	r, err := retentionOptions(cmd)
+
+	if err != nil {
+		return err
+	}
+
+	if r.Enabled() {
+		interval := configValue(cmd, retentionConfigKey, "prune")
+		if interval == "" {
+			interval = "@hourly"
+		}
+
+		s, err := stats.ParseSchedule(interval)
+
+		if err != nil {
+			return fmt.Errorf("--prune: %w", err)
+		}
+		c.SetRetention(r, s)
+	}
+
+	if interval := configValue(cmd, retentionConfigKey, "vacuum"); interval != "" {
+		s, err := stats.ParseSchedule(interval)
+
+		if err != nil {
+			return fmt.Errorf("--vacuum: %w", err)
+		}
+		c.SetVacuum(s)
+	}
+
+	return nil
+}
+
+// parseSchedules returns the schedule for each key of intervals.
+func parseSchedules(intervals map[string]string) (map[string]stats.Schedule, error) {
+	if len(intervals) == 0 {
+		return nil, nil
+	}
+
+	schedules := map[string]stats.Schedule{}
+
+	for key, interval := range intervals {
+		s, err := stats.ParseSchedule(interval)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		schedules[key] = s
+	}
+	return schedules, nil
+}
+
+// initDB opens the database, upgrading its schema if needed. It exits if
+// the database can not be opened or migrated.
+func initDB(dbName string) *sqlx.DB {
+	db, err := stats.OpenDB(dbName)
+	cobra.CheckErr(err)
 
 	return db
 }
@@ -0,0 +1,176 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd groups commands which maintain a collector database
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain a database of collected posts",
+}
+
+// migrateCmd represents the db migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade a database to the latest schema",
+	Long: `Applies any pending schema migrations to a database created by 'collect'.
+Databases are upgraded automatically when opened by other commands.
+
+Example:
+
+List migrations which would be applied to 'outage.db'
+proma db migrate -d outage.db --dry-run
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dbName, _ := cmd.Flags().GetString("database")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if dryRun && dbName != "" && !strings.Contains(dbName, "://") {
+			// a missing file would be created when opened
+			_, err := os.Stat(dbName)
+			cobra.CheckErr(err)
+		}
+
+		db, err := stats.Open(dbName)
+		cobra.CheckErr(err)
+		defer db.Close()
+
+		current, err := stats.SchemaVersion(db)
+		cobra.CheckErr(err)
+
+		migrations, err := stats.Migrate(db, dryRun)
+		cobra.CheckErr(err)
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "current version: %d\n", current)
+
+		if len(migrations) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+			return
+		}
+
+		verb := "applied"
+		if dryRun {
+			verb = "pending"
+		}
+
+		for _, m := range migrations {
+			fmt.Fprintf(out, "%s: %04d_%s\n", verb, m.Version, m.Name)
+		}
+	},
+}
+
+// pruneCmd represents the db prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove posts beyond retention limits from a database",
+	Long: `Removes posts older than --max-age, or beyond the newest --max-posts-per-tag
+of each of their tags, along with their details. Posts starred with 'db star'
+are kept unless --keep-starred=false. Limits not set by a flag are read from
+the 'retention' section of the config file.
+
+Pruning does not shrink the database file. Use --vacuum to reclaim the space.
+
+Example:
+
+Count posts older than 90 days in 'outage.db', without removing them
+proma db prune --max-age 90d --dry-run -d outage.db
+
+Keep the newest 10000 posts of each tag, and reclaim the space of the rest
+proma db prune --max-posts-per-tag 10000 --vacuum -d outage.db
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dbName, _ := cmd.Flags().GetString("database")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		vacuum, _ := cmd.Flags().GetBool("vacuum")
+
+		r, err := retentionOptions(cmd)
+		cobra.CheckErr(err)
+
+		if !r.Enabled() {
+			cobra.CheckErr(errors.New("--max-age or --max-posts-per-tag is required"))
+		}
+
+		var db *sqlx.DB
+		if dryRun {
+			// a dry run leaves the database as it is, and must not create it
+			db = openArchive(dbName)
+		} else {
+			db = initDB(dbName)
+		}
+		defer db.Close()
+
+		c := stats.NewCollector(nil, db)
+
+		n, err := c.Prune(cmd.Context(), r, dryRun)
+		cobra.CheckErr(err)
+
+		out := cmd.OutOrStdout()
+
+		if dryRun {
+			fmt.Fprintf(out, "would prune %d posts, keeping %s\n", n, r)
+			return
+		}
+
+		fmt.Fprintf(out, "pruned %d posts, keeping %s\n", n, r)
+		cobra.CheckErr(c.Optimize(cmd.Context(), vacuum))
+	},
+}
+
+// starCmd represents the db star command
+var starCmd = &cobra.Command{
+	Use:   "star <uri>...",
+	Short: "Keep posts when a database is pruned",
+	Long: `Stars posts by URI, so that they are kept when the database is pruned.
+
+Example:
+
+Keep a post in 'outage.db', however old
+proma db star https://mastodon.social/users/ann/statuses/1 -d outage.db
+
+Allow a post to be pruned again
+proma db star --unstar https://mastodon.social/users/ann/statuses/1 -d outage.db
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbName, _ := cmd.Flags().GetString("database")
+		unstar, _ := cmd.Flags().GetBool("unstar")
+
+		db := initDB(dbName)
+		defer db.Close()
+
+		c := stats.NewCollector(nil, db)
+
+		for _, uri := range args {
+			cobra.CheckErr(c.Star(cmd.Context(), uri, !unstar))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(migrateCmd)
+
+	dbCmd.PersistentFlags().StringP("database", "d", "", "database file, or postgres:// URL")
+	dbCmd.MarkPersistentFlagRequired("database")
+	migrateCmd.Flags().Bool("dry-run", false, "list pending migrations without applying them")
+
+	dbCmd.AddCommand(pruneCmd)
+	addRetentionFlags(pruneCmd)
+	pruneCmd.Flags().Bool("dry-run", false, "count posts which would be pruned without removing them")
+	pruneCmd.Flags().Bool("vacuum", false, "rebuild the database to reclaim the space of pruned posts")
+
+	dbCmd.AddCommand(starCmd)
+	starCmd.Flags().Bool("unstar", false, "allow the posts to be pruned again")
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports on posts from an existing database",
+	Long: `Reports on posts stored by earlier runs of 'collect -d', without
+connecting to any servers. The database is opened read-only.
+
+Example:
+
+Report on posts tagged '#outage' during July, oldest first
+proma report -t outage --from 2023-07-01 --to 2023-08-01 --sort oldest -d outage.db
+
+Report on posts collected by the watch 'outages', using its database and filters
+proma report --watch outages
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if name, _ := cmd.Flags().GetString("watch"); name != "" {
+			applyWatch(cmd, name)
+		}
+
+		dbName, _ := cmd.Flags().GetString("database")
+
+		if dbName == "" {
+			cobra.CheckErr(errors.New("a --database or --watch is required"))
+		}
+
+		tags, _ := cmd.Flags().GetStringSlice("tags")
+
+		db := openArchive(dbName)
+		defer db.Close()
+
+		printReport(cmd, stats.NewCollector(nil, db), tags)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringP("database", "d", "", "database file produced by 'collect -d'")
+	reportCmd.Flags().StringSliceP("tags", "t", []string{}, "tag names (default all tags)")
+	reportCmd.Flags().String("watch", "", "report on a watch saved by 'watch add'")
+	addReportFlags(reportCmd, "2d")
+}
+
+// applyWatch sets the database, tags and report filters of cmd from a saved
+// watch, unless they are set by flags.
+func applyWatch(cmd *cobra.Command, name string) {
+	watches, err := findWatches([]string{name})
+	cobra.CheckErr(err)
+
+	w := watches[0]
+	f := cmd.Flags()
+
+	values := map[string]string{
+		"database": w.Database,
+		"tags":     strings.Join(w.Tags, ","),
+		"lang":     strings.Join(w.Langs, ","),
+		"all-tags": strconv.FormatBool(w.AllTags),
+	}
+
+	for name, value := range values {
+		if f.Lookup(name) != nil && !f.Changed(name) && value != "" {
+			cobra.CheckErr(f.Set(name, value))
+		}
+	}
+}
+
+// openArchive opens an existing database read-only. It exits if the
+// database can not be opened.
+func openArchive(dbName string) *sqlx.DB {
+	db, err := stats.OpenReadOnly(dbName)
+	cobra.CheckErr(err)
+
+	return db
+}
+
+// printReport generates a report using the report flags of cmd, and prints
+// it as JSON.
+func printReport(cmd *cobra.Command, c *stats.Collector, tagNames []string) {
+	opts, err := reportOptions(cmd, tagNames)
+	cobra.CheckErr(err)
+
+	results, err := c.Report(cmd.Context(), opts)
+	cobra.CheckErr(err)
+
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "no results")
+		return
+	}
+
+	printJSON(cmd, results)
+}
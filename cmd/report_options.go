@@ -0,0 +1,65 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"time"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// addReportFlags registers the flags selecting posts included in a report,
+// including posts created since from by default.
+func addReportFlags(cmd *cobra.Command, from string) {
+	f := cmd.Flags()
+	f.String("from", from, "only posts created since a date (2006-01-02) or duration (e.g. 36h, 7d)")
+	f.String("to", "", "only posts created before a date (2006-01-02) or duration (e.g. 1d)")
+	f.StringSlice("lang", []string{}, "only posts in these languages (e.g. en,de)")
+	f.StringSlice("server", []string{}, "only posts collected from these servers")
+	f.StringSlice("account", []string{}, "only posts by these accounts (user@domain, or account ID)")
+	f.Bool("all-tags", false, "only posts with all of the tag names (default any)")
+	f.Bool("no-boosts", false, "omit posts only collected because they were boosted")
+	f.Bool("no-replies", false, "omit replies")
+	f.Bool("no-sensitive", false, "omit posts with a content warning or sensitive media")
+	f.Int("limit", 0, "maximum number of posts (default no limit)")
+	f.Int("offset", 0, "number of posts to skip")
+	f.String("sort", stats.SortNewest, "sort order ('newest', 'oldest' or 'engagement')")
+}
+
+// reportOptions returns the options set by flags registered with addReportFlags.
+func reportOptions(cmd *cobra.Command, tagNames []string) (stats.ReportOptions, error) {
+	f := cmd.Flags()
+	now := time.Now()
+
+	opts := stats.ReportOptions{Tags: tagNames}
+	opts.AllTags, _ = f.GetBool("all-tags")
+	opts.Langs, _ = f.GetStringSlice("lang")
+	opts.Servers, _ = f.GetStringSlice("server")
+	opts.Accounts, _ = f.GetStringSlice("account")
+	opts.ExcludeBoosts, _ = f.GetBool("no-boosts")
+	opts.ExcludeReplies, _ = f.GetBool("no-replies")
+	opts.ExcludeSensitive, _ = f.GetBool("no-sensitive")
+	opts.Limit, _ = f.GetInt("limit")
+	opts.Offset, _ = f.GetInt("offset")
+	opts.Sort, _ = f.GetString("sort")
+
+	if from, _ := f.GetString("from"); from != "" {
+		t, err := stats.ParseSince(from, now)
+		if err != nil {
+			return opts, err
+		}
+		opts.From = t
+	}
+
+	if to, _ := f.GetString("to"); to != "" {
+		t, err := stats.ParseSince(to, now)
+		if err != nil {
+			return opts, err
+		}
+		opts.To = t
+	}
+
+	return opts, nil
+}
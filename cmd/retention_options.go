@@ -0,0 +1,50 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// retentionConfigKey is the section of the config file setting retention
+// limits, with keys matching the flags registered by addRetentionFlags.
+const retentionConfigKey = "retention"
+
+// addRetentionFlags registers the flags limiting the posts kept in a database.
+func addRetentionFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.String("max-age", "", "prune posts created longer ago than this duration (e.g. 90d, 36h)")
+	f.Int("max-posts-per-tag", 0, "prune posts beyond the newest of each of their tags")
+	f.Bool("keep-starred", true, "keep posts starred with 'db star', whatever their age")
+}
+
+// retentionOptions returns the retention limits set by flags registered with
+// addRetentionFlags. Limits not set by a flag are read from the 'retention'
+// section of the config file.
+func retentionOptions(cmd *cobra.Command) (stats.Retention, error) {
+	var (
+		r   stats.Retention
+		err error
+	)
+
+	if maxAge := configValue(cmd, retentionConfigKey, "max-age"); maxAge != "" {
+		if r.MaxAge, err = stats.ParseAge(maxAge); err != nil {
+			return r, fmt.Errorf("--max-age: %w", err)
+		}
+	}
+
+	if r.MaxPostsPerTag, err = strconv.Atoi(configValue(cmd, retentionConfigKey, "max-posts-per-tag")); err != nil || r.MaxPostsPerTag < 0 {
+		return r, fmt.Errorf("--max-posts-per-tag: expected a number of posts")
+	}
+
+	if r.KeepStarred, err = strconv.ParseBool(configValue(cmd, retentionConfigKey, "keep-starred")); err != nil {
+		return r, fmt.Errorf("--keep-starred: expected true or false")
+	}
+
+	return r, nil
+}
@@ -27,6 +27,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ivan3bx/proma/client"
 	"github.com/mattn/go-mastodon"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -89,6 +90,38 @@ func initLogging() {
 	}
 }
 
+// reservedConfigKeys are sections of the config file which do not hold
+// server credentials.
+var reservedConfigKeys = map[string]bool{
+	httpConfigKey:      true,
+	retentionConfigKey: true,
+	watchesConfigKey:   true,
+}
+
+// configValue returns the value of a flag, or the value of its key in a
+// section of the config file if the flag is not set.
+func configValue(cmd *cobra.Command, section, name string) string {
+	key := section + "|" + name
+
+	if !cmd.Flags().Changed(name) && v != nil && v.IsSet(key) {
+		return v.GetString(key)
+	}
+	return cmd.Flags().Lookup(name).Value.String()
+}
+
+// configuredServers returns the names of servers with credentials in the
+// config file.
+func configuredServers() []string {
+	var servers []string
+
+	for _, key := range maps.Keys(v.AllSettings()) {
+		if !reservedConfigKeys[key] {
+			servers = append(servers, key)
+		}
+	}
+	return servers
+}
+
 func initConfig() {
 	v = viper.NewWithOptions(viper.KeyDelimiter("|"))
 
@@ -117,8 +150,8 @@ func initConfig() {
 	defaultServer = allServers[0]
 
 	// default server is overridden by any previous configuration
-	if len(v.AllSettings()) > 0 && !rootCmd.Flags().Changed("servers") {
-		defaultServer = maps.Keys(v.AllSettings())[0]
+	if servers := configuredServers(); len(servers) > 0 && !rootCmd.Flags().Changed("servers") {
+		defaultServer = servers[0]
 		log.Info("using default serverName: ", defaultServer)
 	}
 
@@ -132,7 +165,7 @@ func initConfig() {
 			AccessToken:  configValues["accesstoken"],
 		}
 
-		mClient = mastodon.NewClient(clientConfig)
+		mClient = client.NewClient(clientConfig)
 	} else {
 		log.Debugf("credentials missing for default server '%s'", defaultServer)
 	}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the content of collected posts",
+	Long: `Searches the text of posts stored by 'collect', ordered by relevance.
+Requires a build with sqlite's FTS extension (--tags=sqlite_fts5).
+
+Queries support phrases ("power outage"), prefixes (outa*)
+and boolean operators (storm AND NOT snow).
+
+Example:
+
+Search for posts mentioning power cuts, tagged '#outage'
+proma search '"power cut" OR blackout' -t outage -d outage.db
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbName, _ := cmd.Flags().GetString("database")
+		tags, _ := cmd.Flags().GetStringSlice("tags")
+		langs, _ := cmd.Flags().GetStringSlice("lang")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		db := initDB(dbName)
+		defer db.Close()
+
+		results, err := stats.NewCollector(nil, db).Search(cmd.Context(), stats.SearchOptions{
+			Query: args[0],
+			Tags:  tags,
+			Langs: langs,
+			Limit: limit,
+		})
+		cobra.CheckErr(err)
+
+		if len(results) == 0 {
+			fmt.Fprintln(os.Stderr, "no results")
+			return
+		}
+
+		printJSON(cmd, results)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringP("database", "d", "", "database file to search")
+	searchCmd.Flags().StringSliceP("tags", "t", []string{}, "only posts with any of these tag names")
+	searchCmd.Flags().StringSlice("lang", []string{}, "only posts in these languages (e.g. en,de)")
+	searchCmd.Flags().Int("limit", 20, "maximum number of results")
+	searchCmd.MarkFlagRequired("database")
+}
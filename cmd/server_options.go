@@ -0,0 +1,56 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// httpConfigKey is the section of the config file configuring the stats
+// server, with keys matching the flags registered by addServerFlags.
+const httpConfigKey = "http"
+
+// addServerFlags registers the flags configuring the stats server.
+func addServerFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.String("listen", stats.DefaultListen, "address for the stats server (host:port, or unix:/path/to/socket)")
+	f.String("tls-cert", "", "certificate file to serve the stats server over HTTPS")
+	f.String("tls-key", "", "private key file for --tls-cert")
+	f.Bool("self-signed", false, "serve over HTTPS with a generated, self-signed certificate")
+	f.String("basic-auth", "", "require HTTP basic auth credentials (user:password)")
+	f.String("token", "", "require an 'Authorization: Bearer' token")
+}
+
+// serverOptions returns the stats server options set by flags registered
+// with addServerFlags. Options not set by a flag are read from the 'http'
+// section of the config file.
+func serverOptions(cmd *cobra.Command) (stats.ServerOptions, error) {
+	var opts stats.ServerOptions
+
+	opts.Listen = configValue(cmd, httpConfigKey, "listen")
+	opts.TLSCert = configValue(cmd, httpConfigKey, "tls-cert")
+	opts.TLSKey = configValue(cmd, httpConfigKey, "tls-key")
+	opts.BearerToken = configValue(cmd, httpConfigKey, "token")
+	opts.SelfSigned, _ = strconv.ParseBool(configValue(cmd, httpConfigKey, "self-signed"))
+
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return opts, fmt.Errorf("both --tls-cert and --tls-key are required to serve HTTPS")
+	}
+
+	if auth := configValue(cmd, httpConfigKey, "basic-auth"); auth != "" {
+		user, pass, ok := strings.Cut(auth, ":")
+
+		if !ok || user == "" {
+			return opts, fmt.Errorf("basic auth must be formatted as user:password")
+		}
+		opts.Username, opts.Password = user, pass
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarizes tag volume over time from an existing database",
+	Long: `Counts posts per tag in each interval, with the change from the previous
+interval (velocity), a rolling average and spikes in volume. The database
+is opened read-only.
+
+A spike is an interval where the number of posts is at least 'threshold'
+standard deviations above the average of the preceding 'window' intervals.
+
+Example:
+
+Hourly volume for '#outage' over the last 3 days, showing only spikes
+proma stats -t outage --from 3d --interval hour --spikes -d outage.db
+
+The 10 accounts posting most often with '#outage' over the last week
+proma stats -t outage --top-posters 10 -d outage.db
+
+How far posts tagged '#outage' spread across the servers collected from
+proma stats -t outage --reach -d outage.db
+
+The 10 posts tagged '#outage' gaining the most replies, boosts and favourites
+since they were collected, as found by 'collect --recheck'
+proma stats -t outage --top-growth 10 -d outage.db
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dbName, _ := cmd.Flags().GetString("database")
+		tags, _ := cmd.Flags().GetStringSlice("tags")
+		spikesOnly, _ := cmd.Flags().GetBool("spikes")
+		byServer, _ := cmd.Flags().GetBool("by-server")
+		topPosters, _ := cmd.Flags().GetInt("top-posters")
+		topGrowth, _ := cmd.Flags().GetInt("top-growth")
+		reach, _ := cmd.Flags().GetBool("reach")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		reportOpts, err := reportOptions(cmd, tags)
+		cobra.CheckErr(err)
+
+		opts := stats.TrendOptions{ReportOptions: reportOpts}
+		opts.Interval, _ = cmd.Flags().GetString("interval")
+		opts.Window, _ = cmd.Flags().GetInt("window")
+		opts.Threshold, _ = cmd.Flags().GetFloat64("threshold")
+
+		db := openArchive(dbName)
+		defer db.Close()
+
+		c := stats.NewCollector(nil, db)
+
+		if reach {
+			results, err := c.TagReach(cmd.Context(), reportOpts)
+			cobra.CheckErr(err)
+
+			if asJSON {
+				printJSON(cmd, results)
+				return
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TAG\tPOSTS\tORIGINS\tSERVERS\tAVERAGE REACH")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.2f\n", r.Tag, r.Posts, r.Origins, r.Servers, r.AverageReach)
+			}
+			w.Flush()
+			return
+		}
+
+		if topGrowth > 0 {
+			growth, err := c.TopGrowth(cmd.Context(), reportOpts, topGrowth)
+			cobra.CheckErr(err)
+
+			if asJSON {
+				printJSON(cmd, growth)
+				return
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TAG\tPOST\tACCOUNT\tREPLIES\tBOOSTS\tFAVOURITES\tGROWTH")
+			for _, g := range growth {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%+d\t%+d\t%+d\t%+d\n", g.Tag, g.URI, g.Account, g.Replies, g.Reblogs, g.Favourites, g.Growth)
+			}
+			w.Flush()
+			return
+		}
+
+		if topPosters > 0 {
+			posters, err := c.TopPosters(cmd.Context(), reportOpts, topPosters)
+			cobra.CheckErr(err)
+
+			if asJSON {
+				printJSON(cmd, posters)
+				return
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TAG\tACCOUNT\tNAME\tPOSTS\tFOLLOWERS")
+			for _, p := range posters {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", p.Tag, p.Acct, p.DisplayName, p.Count, p.FollowersCount)
+			}
+			w.Flush()
+			return
+		}
+
+		if byServer {
+			counts, err := c.ServerCounts(cmd.Context(), opts)
+			cobra.CheckErr(err)
+
+			if asJSON {
+				printJSON(cmd, counts)
+				return
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVER\tTAG\tPOSTS")
+			for _, sc := range counts {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", sc.Server, sc.Tag, sc.Count)
+			}
+			w.Flush()
+			return
+		}
+
+		trends, err := c.TagTrends(cmd.Context(), opts)
+		cobra.CheckErr(err)
+
+		if len(trends) == 0 {
+			fmt.Fprintln(os.Stderr, "no results")
+			return
+		}
+
+		if spikesOnly {
+			for i := range trends {
+				trends[i].Buckets = trends[i].Spikes()
+			}
+		}
+
+		if asJSON {
+			printJSON(cmd, trends)
+			return
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "TAG\tSTART\tPOSTS\tVELOCITY\tAVERAGE\tZ-SCORE\t\t")
+		for _, t := range trends {
+			for _, b := range t.Buckets {
+				spike := ""
+				if b.Spike {
+					spike = yellow("spike")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%+d\t%.1f\t%.2f\t%s\t\n",
+					t.Tag, b.Start.Local().Format("2006-01-02 15:04"), b.Count, b.Velocity, b.Average, b.ZScore, spike)
+			}
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	f := statsCmd.Flags()
+	f.StringP("database", "d", "", "database file produced by 'collect -d'")
+	f.StringSliceP("tags", "t", []string{}, "tag names (default all tags)")
+	f.String("interval", stats.IntervalHour, "bucket posts by 'minute', 'hour' or 'day'")
+	f.Int("window", 24, "number of preceding intervals used for averages")
+	f.Float64("threshold", 3, "z-score at which an interval is a spike")
+	f.Bool("spikes", false, "only show intervals detected as spikes")
+	f.Bool("by-server", false, "show total posts per tag for each server")
+	f.Bool("reach", false, "show how many instances posts with each tag came from, and how many servers saw them")
+	f.Int("top-posters", 0, "show up to this many accounts with the most posts for each tag")
+	f.Int("top-growth", 0, "show up to this many posts with the most growth in engagement for each tag")
+	f.Bool("json", false, "print results as JSON")
+	addReportFlags(statsCmd, "7d")
+	statsCmd.MarkFlagRequired("database")
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(cmd *cobra.Command, v any) {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	cobra.CheckErr(enc.Encode(v))
+}
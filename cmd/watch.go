@@ -0,0 +1,246 @@
+/*
+Copyright © 2022 Ivan Moscoso
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ivan3bx/proma/stats"
+	"github.com/spf13/cobra"
+)
+
+// watchesConfigKey is the section of the config file storing watches.
+const watchesConfigKey = "watches"
+
+// watchConfig is a named collection configuration stored in the config file.
+type watchConfig struct {
+	Name     string   `mapstructure:"-" json:"-"`
+	Tags     []string `mapstructure:"tags" json:"tags"`
+	Servers  []string `mapstructure:"servers" json:"servers"`
+	Interval string   `mapstructure:"interval" json:"interval,omitempty"`
+	Database string   `mapstructure:"database" json:"database,omitempty"`
+	Langs    []string `mapstructure:"lang" json:"lang,omitempty"`
+	AllTags  bool     `mapstructure:"all-tags" json:"all-tags,omitempty"`
+
+	TagIntervals    map[string]string `mapstructure:"tag-intervals" json:"tag-intervals,omitempty"`
+	ServerIntervals map[string]string `mapstructure:"server-intervals" json:"server-intervals,omitempty"`
+}
+
+// watchCmd groups commands which maintain watches
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Manage saved collection configurations",
+	Long: `Watches are named sets of tags and servers, stored in the config file,
+which may be collected together by 'collect --watch'.`,
+}
+
+// watchAddCmd represents the watch add command
+var watchAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Save a watch to the config file",
+	Long: `Saves the tags, servers, interval, database and report filters to collect
+as a named watch. An existing watch with the same name is replaced.
+
+Example:
+
+Watch '#outage' and '#poweroutage' on two servers every 2 minutes
+proma watch add outages -t outage,poweroutage -s mastodon.social,hachyderm.io -i 2m -d outage.db
+
+Watch '#flood' hourly, but every 5 minutes on 'hachyderm.io'
+proma watch add floods -t flood -s mastodon.social,hachyderm.io -i @hourly --server-interval hachyderm.io=5m
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f := cmd.Flags()
+
+		w := watchConfig{Name: strings.ToLower(args[0]), Servers: allServers}
+		w.Tags, _ = f.GetStringSlice("tags")
+		w.Interval, _ = f.GetString("interval")
+		w.TagIntervals, _ = f.GetStringToString("tag-interval")
+		w.ServerIntervals, _ = f.GetStringToString("server-interval")
+		w.Database, _ = f.GetString("database")
+		w.Langs, _ = f.GetStringSlice("lang")
+		w.AllTags, _ = f.GetBool("all-tags")
+
+		cobra.CheckErr(w.validate())
+
+		watches, err := loadWatches()
+		cobra.CheckErr(err)
+
+		watches[w.Name] = w
+		cobra.CheckErr(saveWatches(watches))
+
+		fmt.Fprintf(cmd.OutOrStdout(), "saved watch '%s'\n", w.Name)
+	},
+}
+
+// watchListCmd represents the watch list command
+var watchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List watches saved in the config file",
+	Run: func(cmd *cobra.Command, args []string) {
+		watches, err := loadWatches()
+		cobra.CheckErr(err)
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if asJSON {
+			printJSON(cmd, watches)
+			return
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTAGS\tSERVERS\tINTERVAL\tDATABASE")
+
+		for _, name := range sortedWatchNames(watches) {
+			wc := watches[name]
+			interval, database := wc.Interval, wc.Database
+
+			if interval == "" {
+				interval = "default"
+			}
+			if database == "" {
+				database = "(in-memory)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name,
+				strings.Join(wc.Tags, ","), strings.Join(wc.Servers, ","), interval, database)
+		}
+		w.Flush()
+	},
+}
+
+// watchRemoveCmd represents the watch remove command
+var watchRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a watch from the config file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.ToLower(args[0])
+
+		watches, err := loadWatches()
+		cobra.CheckErr(err)
+
+		if _, ok := watches[name]; !ok {
+			cobra.CheckErr(fmt.Errorf("no watch named '%s'", name))
+		}
+
+		delete(watches, name)
+		cobra.CheckErr(saveWatches(watches))
+
+		fmt.Fprintf(cmd.OutOrStdout(), "removed watch '%s'\n", name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.AddCommand(watchAddCmd, watchListCmd, watchRemoveCmd)
+
+	f := watchAddCmd.Flags()
+	f.StringSliceP("tags", "t", []string{}, "tag names")
+	addScheduleFlags(watchAddCmd)
+	f.StringP("database", "d", "", "database file, or postgres:// URL, to store results (default in-memory)")
+	f.StringSlice("lang", []string{}, "only report posts in these languages (e.g. en,de)")
+	f.Bool("all-tags", false, "only report posts with all of the tag names (default any)")
+	watchAddCmd.MarkFlagRequired("tags")
+
+	watchListCmd.Flags().Bool("json", false, "print watches as JSON")
+}
+
+// validate returns an error if the watch can not be collected.
+func (w watchConfig) validate() error {
+	if len(w.Tags) == 0 {
+		return fmt.Errorf("watch '%s' has no tags", w.Name)
+	}
+
+	if len(w.Servers) == 0 {
+		return fmt.Errorf("watch '%s' has no servers", w.Name)
+	}
+
+	_, err := w.watch()
+	return err
+}
+
+// watch returns the watch with its schedules, but without clients.
+func (w watchConfig) watch() (stats.Watch, error) {
+	result := stats.Watch{Name: w.Name, Tags: w.Tags}
+
+	var err error
+
+	if w.Interval != "" {
+		if result.Schedule, err = stats.ParseSchedule(w.Interval); err != nil {
+			return result, fmt.Errorf("watch '%s': %w", w.Name, err)
+		}
+	}
+
+	if result.TagSchedules, err = parseSchedules(w.TagIntervals); err != nil {
+		return result, fmt.Errorf("watch '%s': %w", w.Name, err)
+	}
+
+	if result.ServerSchedules, err = parseSchedules(w.ServerIntervals); err != nil {
+		return result, fmt.Errorf("watch '%s': %w", w.Name, err)
+	}
+
+	return result, nil
+}
+
+// loadWatches returns the watches saved in the config file, by name.
+func loadWatches() (map[string]watchConfig, error) {
+	watches := map[string]watchConfig{}
+
+	if err := v.UnmarshalKey(watchesConfigKey, &watches); err != nil {
+		return nil, err
+	}
+
+	for name, w := range watches {
+		w.Name = name
+		watches[name] = w
+	}
+	return watches, nil
+}
+
+// findWatches returns the saved watches with the provided names.
+func findWatches(names []string) ([]watchConfig, error) {
+	watches, err := loadWatches()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var results []watchConfig
+
+	for _, name := range names {
+		w, ok := watches[strings.ToLower(name)]
+
+		if !ok {
+			return nil, fmt.Errorf("no watch named '%s' (see 'proma watch list')", name)
+		}
+
+		if err := w.validate(); err != nil {
+			return nil, err
+		}
+		results = append(results, w)
+	}
+	return results, nil
+}
+
+// saveWatches replaces the watches saved in the config file. The watches are
+// set as a single value, so that removed watches are not merged back in from
+// the config file when it is written.
+func saveWatches(watches map[string]watchConfig) error {
+	v.Set(watchesConfigKey, watches)
+	return v.WriteConfig()
+}
+
+func sortedWatchNames(watches map[string]watchConfig) []string {
+	names := make([]string, 0, len(watches))
+	for name := range watches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Account is the author of collected posts, as last seen by the collector.
+type Account struct {
+	// Acct is the canonical address of the account (user@domain).
+	Acct           string         `json:"acct" db:"acct"`
+	Username       string         `json:"username" db:"username"`
+	DisplayName    string         `json:"display_name" db:"display_name"`
+	URL            string         `json:"url" db:"url"`
+	Bot            bool           `json:"bot" db:"bot"`
+	Locked         bool           `json:"locked" db:"locked"`
+	FollowersCount int64          `json:"followers_count" db:"followers_count"`
+	FollowingCount int64          `json:"following_count" db:"following_count"`
+	StatusesCount  int64          `json:"statuses_count" db:"statuses_count"`
+	CreatedAt      sqliteDatetime `json:"created_at" db:"created_at"`
+}
+
+// Poster is an account and the number of its posts with a tag.
+type Poster struct {
+	Tag string `json:"tag" db:"tag"`
+	Account
+	Count int `json:"count" db:"count"`
+}
+
+// canonicalAcct returns the address of an account seen on a server. Local
+// accounts are returned by servers without a domain, which is that of the
+// server. It returns an empty string if the account has no address.
+func canonicalAcct(server string, a mastodon.Account) string {
+	if a.Acct == "" {
+		return ""
+	}
+
+	if strings.Contains(a.Acct, "@") {
+		return strings.ToLower(a.Acct)
+	}
+
+	u, err := url.Parse(server)
+
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(a.Acct + "@" + u.Host)
+}
+
+// upsertAccount stores the account under its canonical address, updating
+// its profile and counts if already stored.
+func (w *writeTx) upsertAccount(acct string, a mastodon.Account) error {
+	_, err := w.exec(`
+		INSERT INTO accounts (
+			acct,
+			username,
+			display_name,
+			url,
+			bot,
+			locked,
+			followers_count,
+			following_count,
+			statuses_count,
+			created_at,
+			updated_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+		ON CONFLICT (acct) DO UPDATE SET
+			username = excluded.username,
+			display_name = excluded.display_name,
+			url = excluded.url,
+			bot = excluded.bot,
+			locked = excluded.locked,
+			followers_count = excluded.followers_count,
+			following_count = excluded.following_count,
+			statuses_count = excluded.statuses_count,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at;`,
+		acct,
+		a.Username,
+		a.DisplayName,
+		a.URL,
+		a.Bot,
+		a.Locked,
+		a.FollowersCount,
+		a.FollowingCount,
+		a.StatusesCount,
+		a.CreatedAt.UTC(),
+		time.Now().UTC(),
+	)
+
+	return err
+}
+
+// TopPosters returns up to limit accounts with the most posts for each tag,
+// ordered by tag and then by number of posts. Posts collected before
+// accounts were stored are not counted.
+func (s *sqlStore) TopPosters(ctx context.Context, opts ReportOptions, limit int) ([]Poster, error) {
+	var results []Poster
+
+	where, args := TrendOptions{ReportOptions: opts}.tagWhere()
+	args = append(args, limit)
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			tag, acct, username, display_name, url, bot, locked,
+			followers_count, following_count, statuses_count, created_at, count
+		FROM (
+			SELECT
+				t.name tag,
+				a.*,
+				COUNT(*) count,
+				ROW_NUMBER() OVER (PARTITION BY t.name ORDER BY COUNT(*) DESC, a.acct) AS position
+			FROM
+				posts p
+			INNER JOIN
+				accounts a ON a.acct = p.acct
+			INNER JOIN
+				posts_tags pt ON pt.post_id = p.id
+			INNER JOIN
+				tags t ON t.id = pt.tag_id
+			WHERE
+				`+strings.Join(where, " AND ")+`
+			GROUP BY t.name, a.acct
+		) ranked
+		WHERE position <= ?
+		ORDER BY tag, count DESC, acct;
+	`, args...)
+
+	return results, err
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestCanonicalAcct(t *testing.T) {
+	testCases := []struct {
+		name     string
+		server   string
+		acct     string
+		expected string
+	}{
+		{name: "local", server: "https://mastodon.social", acct: "Gargron", expected: "gargron@mastodon.social"},
+		{name: "remote", server: "https://mastodon.social", acct: "ivan@Hachyderm.io", expected: "ivan@hachyderm.io"},
+		{name: "missing", server: "https://mastodon.social", acct: "", expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := canonicalAcct(tc.server, mastodon.Account{Acct: tc.acct})
+
+			if actual != tc.expected {
+				t.Errorf("expected acct to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTopPosters(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC()
+
+	ann := mastodon.Account{ID: "1", Acct: "ann", Username: "ann", FollowersCount: 10}
+	bob := mastodon.Account{ID: "9", Acct: "bob@hachyderm.io", Username: "bob", Bot: true}
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Account: ann, Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-3 * time.Hour)},
+		&mastodon.Status{URI: "https://a.example/2", Account: bob, Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: now.Add(-2 * time.Hour)},
+	)
+
+	// the same accounts seen from another server, with updated counts
+	ann.Acct = "ann@mastodon.social"
+	ann.FollowersCount = 12
+	bob.Acct = "bob"
+
+	insertTestStatuses(t, c, "https://hachyderm.io",
+		&mastodon.Status{URI: "https://a.example/3", Account: ann, Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)},
+		&mastodon.Status{URI: "https://b.example/1", Account: bob, Tags: []mastodon.Tag{{Name: "power"}}, CreatedAt: now.Add(-time.Hour)},
+	)
+
+	testCases := []struct {
+		name     string
+		opts     ReportOptions
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "all tags",
+			limit:    10,
+			expected: []string{"outage:ann@mastodon.social:2:12:false", "outage:bob@hachyderm.io:1:0:true", "power:bob@hachyderm.io:2:0:true"},
+		},
+		{
+			name:     "limit per tag",
+			opts:     ReportOptions{Tags: []string{"outage"}},
+			limit:    1,
+			expected: []string{"outage:ann@mastodon.social:2:12:false"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			posters, err := c.TopPosters(context.Background(), tc.opts, tc.limit)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, p := range posters {
+				actual = append(actual, fmt.Sprintf("%s:%s:%d:%d:%v", p.Tag, p.Acct, p.Count, p.FollowersCount, p.Bot))
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected posters to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+
+	results, err := c.Report(context.Background(), ReportOptions{Accounts: []string{"Bob@hachyderm.io"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Account != "bob@hachyderm.io" {
+		t.Errorf("expected posts by account, was: %+v\n", results)
+	}
+}
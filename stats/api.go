@@ -0,0 +1,378 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes for the posts API.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// postsPage is a page of posts returned by the API. NextCursor is empty
+// on the last page.
+type postsPage struct {
+	Posts      []*Status `json:"posts"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// apiServer is the number of posts collected from a server, with the
+// health of its collection when collecting.
+type apiServer struct {
+	ServerSummary
+	Health *ServerHealth `json:"health,omitempty"`
+}
+
+// registerAPI adds the JSON API for collected data under /api/v1.
+func (s *Server) registerAPI(e *gin.Engine) {
+	api := e.Group("/api/v1")
+
+	api.GET("/posts", s.apiPosts)
+	api.GET("/tags", s.apiTags)
+	api.GET("/tags/:name/timeseries", s.apiTimeseries)
+	api.GET("/servers", s.apiServers)
+	api.GET("/posters", s.apiPosters)
+	api.GET("/reach", s.apiReach)
+	api.GET("/growth", s.apiGrowth)
+}
+
+// apiPosts returns a page of posts, newest first. Posts may be filtered
+// with 'tag', 'server', 'lang', 'from' and 'to', and the next page is
+// requested by passing 'next_cursor' as 'cursor'.
+func (s *Server) apiPosts(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts.Sort = c.DefaultQuery("sort", SortNewest)
+	opts.Cursor = c.Query("cursor")
+	opts.Limit, err = queryInt(c, "limit", defaultPageSize)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if opts.Limit <= 0 || opts.Limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	posts, err := s.collector.Report(c, opts)
+
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := postsPage{Posts: posts}
+
+	if page.Posts == nil {
+		page.Posts = []*Status{}
+	}
+
+	if len(posts) == opts.Limit {
+		page.NextCursor = posts[len(posts)-1].Cursor()
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// apiTags returns the number of posts collected for each tag.
+func (s *Server) apiTags(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := s.collector.TagSummaries(c, opts)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tags == nil {
+		tags = []TagSummary{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// apiTimeseries returns post counts for a tag, bucketed by 'interval'
+// (default hour), over the last 24 hours unless 'from' is set.
+func (s *Server) apiTimeseries(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts.Tags = []string{strings.TrimPrefix(c.Param("name"), "#")}
+
+	if opts.From.IsZero() {
+		opts.From = time.Now().Add(-24 * time.Hour)
+	}
+
+	trendOpts := TrendOptions{
+		ReportOptions: opts,
+		Interval:      c.DefaultQuery("interval", IntervalHour),
+	}
+
+	trendOpts.Window, err = queryInt(c, "window", 6)
+
+	if err == nil {
+		trendOpts.Threshold, err = strconv.ParseFloat(c.DefaultQuery("threshold", "3"), 64)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := bucketFormats[trendOpts.Interval]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown interval: " + trendOpts.Interval})
+		return
+	}
+
+	trends, err := s.collector.TagTrends(c, trendOpts)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(trends) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no posts for tag: " + opts.Tags[0]})
+		return
+	}
+
+	c.JSON(http.StatusOK, trends[0])
+}
+
+// apiServers returns the number of posts collected from each server,
+// along with collection health for the servers being polled.
+func (s *Server) apiServers(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries, err := s.collector.ServerSummaries(c, opts)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	health := map[string]ServerHealth{}
+	for _, h := range s.collector.Health() {
+		health[h.Server] = h
+	}
+
+	servers := make([]apiServer, 0, len(summaries))
+
+	for _, summary := range summaries {
+		server := apiServer{ServerSummary: summary}
+
+		if h, ok := health[summary.Server]; ok {
+			server.Health = &h
+		}
+
+		servers = append(servers, server)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"servers": servers})
+}
+
+// apiPosters returns the accounts with the most posts for each tag, up to
+// 'limit' (default 10) accounts per tag.
+func (s *Server) apiPosters(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := queryInt(c, "limit", 10)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if limit <= 0 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	posters, err := s.collector.TopPosters(c, opts, limit)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if posters == nil {
+		posters = []Poster{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"posters": posters})
+}
+
+// apiReach returns how widely the posts with each tag have federated
+// across the servers being collected from.
+func (s *Server) apiReach(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reach, err := s.collector.TagReach(c, opts)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if reach == nil {
+		reach = []TagReach{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": reach})
+}
+
+// apiReportOptions returns the filters common to API requests. Tags,
+// servers and languages may be repeated or comma-separated; 'from' and
+// 'to' accept the same values as ParseSince. Boosts, replies and sensitive
+// posts are omitted by listing them in 'exclude'.
+func apiReportOptions(c *gin.Context) (ReportOptions, error) {
+	opts := ReportOptions{
+		Tags:    queryList(c, "tag"),
+		Servers: queryList(c, "server"),
+		Langs:   queryList(c, "lang"),
+	}
+
+	for _, exclude := range queryList(c, "exclude") {
+		switch exclude {
+		case "boosts":
+			opts.ExcludeBoosts = true
+		case "replies":
+			opts.ExcludeReplies = true
+		case "sensitive":
+			opts.ExcludeSensitive = true
+		default:
+			return opts, fmt.Errorf("invalid exclude: %s (expected boosts, replies or sensitive)", exclude)
+		}
+	}
+
+	now := time.Now()
+
+	if from := c.Query("from"); from != "" {
+		t, err := ParseSince(from, now)
+
+		if err != nil {
+			return opts, err
+		}
+		opts.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := ParseSince(to, now)
+
+		if err != nil {
+			return opts, err
+		}
+		opts.To = t
+	}
+
+	return opts, nil
+}
+
+// queryList returns the values of a repeated or comma-separated parameter.
+func queryList(c *gin.Context, key string) []string {
+	var results []string
+
+	for _, value := range c.QueryArray(key) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				results = append(results, v)
+			}
+		}
+	}
+	return results
+}
+
+// queryInt returns the integer value of a parameter, or def if not set.
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	value := c.Query(key)
+
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return n, nil
+}
+
+// apiGrowth returns the posts whose engagement grew the most for each tag,
+// up to 'limit' (default 10) posts per tag.
+func (s *Server) apiGrowth(c *gin.Context) {
+	opts, err := apiReportOptions(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := queryInt(c, "limit", 10)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if limit <= 0 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return
+	}
+
+	growth, err := s.collector.TopGrowth(c, opts, limit)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if growth == nil {
+		growth = []PostGrowth{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"posts": growth})
+}
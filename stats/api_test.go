@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestAPI(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC().Truncate(time.Hour)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Language: "en", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-3 * time.Hour)},
+		&mastodon.Status{URI: "https://a.example/2", Language: "de", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: now.Add(-2 * time.Hour)},
+	)
+	insertTestStatuses(t, c, "https://hachyderm.io",
+		&mastodon.Status{URI: "https://b.example/1", Language: "en", Account: mastodon.Account{Acct: "ops", Username: "ops"}, Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)},
+	)
+
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{})
+
+	testCases := []struct {
+		name        string
+		path        string
+		expectCode  int
+		expectMatch string
+	}{
+		{
+			name:        "posts",
+			path:        "/api/v1/posts?tag=outage&lang=de",
+			expectCode:  200,
+			expectMatch: `"uri":"https://a.example/2"`,
+		},
+		{
+			name:        "posts by server",
+			path:        "/api/v1/posts?server=hachyderm.io",
+			expectCode:  200,
+			expectMatch: `"uri":"https://b.example/1"`,
+		},
+		{
+			name:        "no posts",
+			path:        "/api/v1/posts?tag=missing",
+			expectCode:  200,
+			expectMatch: `"posts":[]`,
+		},
+		{
+			name:       "invalid cursor",
+			path:       "/api/v1/posts?cursor=x",
+			expectCode: 400,
+		},
+		{
+			name:       "invalid limit",
+			path:       "/api/v1/posts?limit=0",
+			expectCode: 400,
+		},
+		{
+			name:       "invalid from",
+			path:       "/api/v1/posts?from=yesterday",
+			expectCode: 400,
+		},
+		{
+			name:        "tags",
+			path:        "/api/v1/tags",
+			expectCode:  200,
+			expectMatch: `{"tag":"outage","count":3,`,
+		},
+		{
+			name:        "timeseries",
+			path:        "/api/v1/tags/outage/timeseries?interval=hour&from=6h",
+			expectCode:  200,
+			expectMatch: `"total":3`,
+		},
+		{
+			name:       "timeseries unknown tag",
+			path:       "/api/v1/tags/missing/timeseries",
+			expectCode: 404,
+		},
+		{
+			name:       "timeseries unknown interval",
+			path:       "/api/v1/tags/outage/timeseries?interval=week",
+			expectCode: 400,
+		},
+		{
+			name:        "servers",
+			path:        "/api/v1/servers",
+			expectCode:  200,
+			expectMatch: `{"server":"https://hachyderm.io","count":1,`,
+		},
+		{
+			name:        "posters",
+			path:        "/api/v1/posters?tag=outage",
+			expectCode:  200,
+			expectMatch: `{"tag":"outage","acct":"ops@hachyderm.io","username":"ops",`,
+		},
+		{
+			name:       "invalid posters limit",
+			path:       "/api/v1/posters?limit=-1",
+			expectCode: 400,
+		},
+		{
+			name:        "growth",
+			path:        "/api/v1/growth?tag=outage",
+			expectCode:  200,
+			expectMatch: `{"posts":[]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.web.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.expectCode {
+				t.Fatalf("expected status to match (%v / %v): %s\n", tc.expectCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.expectMatch) {
+				t.Errorf("expected body to contain %q, was: %s\n", tc.expectMatch, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIPagination(t *testing.T) {
+	c := newTestCollector(t)
+	createdAt := time.Now().UTC()
+
+	for i := 0; i < 5; i++ {
+		// posts 1 and 2 share a creation date, and are ordered by ID
+		insertTestStatuses(t, c, "https://mastodon.social", &mastodon.Status{
+			URI:       fmt.Sprintf("https://a.example/%d", i),
+			Tags:      []mastodon.Tag{{Name: "outage"}},
+			CreatedAt: createdAt.Add(time.Duration(i/2) * time.Minute),
+		})
+	}
+
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{})
+
+	var (
+		uris   []string
+		cursor string
+	)
+
+	for pages := 0; pages < 5; pages++ {
+		rec := httptest.NewRecorder()
+		s.web.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/posts?limit=2&cursor="+cursor, nil))
+
+		var page struct {
+			Posts []struct {
+				URI string `json:"uri"`
+			} `json:"posts"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range page.Posts {
+			uris = append(uris, p.URI)
+		}
+
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	expected := "https://a.example/4,https://a.example/3,https://a.example/2,https://a.example/1,https://a.example/0"
+
+	if actual := strings.Join(uris, ","); actual != expected {
+		t.Errorf("expected pages to match (%v / %v)\n", expected, actual)
+	}
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authenticate returns middleware accepting requests which provide either
+// the configured basic auth credentials or bearer token.
+func authenticate(opts ServerOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if opts.Username != "" {
+			if user, pass, ok := c.Request.BasicAuth(); ok && secureEqual(user, opts.Username) && secureEqual(pass, opts.Password) {
+				c.Next()
+				return
+			}
+		}
+
+		if opts.BearerToken != "" {
+			header := c.GetHeader("Authorization")
+
+			if strings.HasPrefix(header, "Bearer ") && secureEqual(strings.TrimPrefix(header, "Bearer "), opts.BearerToken) {
+				c.Next()
+				return
+			}
+		}
+
+		if opts.Username != "" {
+			c.Header("WWW-Authenticate", `Basic realm="proma"`)
+		} else {
+			c.Header("WWW-Authenticate", `Bearer realm="proma"`)
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
+
+// secureEqual compares secrets in constant time. Values are hashed first so
+// that the comparison does not reveal their length.
+func secureEqual(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// selfSignedCertificate generates a certificate for local use, valid for a
+// year for localhost and the provided host.
+func selfSignedCertificate(host string, now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"proma"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "" && ip == nil && host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
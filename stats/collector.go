@@ -3,6 +3,9 @@ package stats
 import (
 	"context"
 	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ivan3bx/proma/client"
@@ -14,187 +17,678 @@ import (
 )
 
 type Collector struct {
-	clients    []*mastodon.Client
-	db         *sqlx.DB
-	sampleRate time.Duration
-	stop       chan struct{}
+	Store
+
+	clients       []*mastodon.Client
+	schedule      Schedule
+	workers       int
+	serverTimeout time.Duration
+	stop          chan struct{}
+
+	// recheck schedules rechecks of posts created within the window
+	recheck       Schedule
+	recheckWindow time.Duration
+	purgeDeleted  bool
+
+	// prune schedules pruning of posts beyond the retention limits, and
+	// vacuum schedules reclaiming their space
+	retention Retention
+	prune     Schedule
+	vacuum    Schedule
+
+	health  healthTracker
+	events  broker
+	metrics collectorMetrics
 }
 
+// NewCollector returns a collector storing posts from the clients in the
+// database, through the Store for its driver.
 func NewCollector(clients []*mastodon.Client, db *sqlx.DB) *Collector {
 	return &Collector{
-		db:         db,
-		clients:    clients,
-		sampleRate: time.Minute * 1,
+		Store:         NewStore(db),
+		clients:       clients,
+		schedule:      Every(time.Minute),
+		workers:       4,
+		serverTimeout: time.Minute * 2,
 	}
 }
 
+// SetWorkers sets the number of servers polled concurrently.
+func (c *Collector) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.workers = n
+}
+
+// SetSchedule sets when tags are collected by Start, and by watches
+// without a schedule of their own.
+func (c *Collector) SetSchedule(s Schedule) {
+	c.schedule = s
+}
+
+// Health returns collection results for each server accumulated across runs.
+func (c *Collector) Health() []ServerHealth {
+	return c.health.snapshot()
+}
+
+// Subscribe returns a channel receiving an Event for each post stored by
+// the collector, and the updated post count of its tags. Call the returned
+// function to unsubscribe once done.
+func (c *Collector) Subscribe() (<-chan Event, func()) {
+	return c.events.subscribe()
+}
+
+// maxPages limits the number of pages fetched for a single tag in one
+// collection run. Any remaining pages are picked up by the next run.
+const maxPages = 20
+
+// budgetReserve is the fraction of a server's rate limit which collection
+// and rechecks leave unused, pausing once fewer requests remain before the
+// limit resets. The reserve keeps requests from other clients sharing the
+// account, such as 'proma auth' or a browser, from being throttled.
+const budgetReserve = 0.1
+
 // Collect performs a single collection of timeline for the provided tags
-// and imports it to the database configured on the collector.
-// It returns an error returned by the server or nil if successful.
-func (c *Collector) Collect(ctx context.Context, tagNames []string) error {
-	for _, cl := range c.clients {
+// and imports it to the database configured on the collector. Timelines are
+// paged forward from the newest post seen on a previous run.
+//
+// Servers are polled concurrently, and a failure on one server does not stop
+// collection from the others. It returns a summary of the run, and an error
+// only if collection failed for every server.
+func (c *Collector) Collect(ctx context.Context, tagNames []string) (*RunSummary, error) {
+	return c.collect(ctx, c.clients, tagNames)
+}
+
+// collect performs a single collection of the tags from each of the clients.
+func (c *Collector) collect(ctx context.Context, clients []*mastodon.Client, tagNames []string) (*RunSummary, error) {
+	return c.collectTags(ctx, clients, func(*mastodon.Client) []string { return tagNames }, nil)
+}
+
+// collectTags performs a single collection of the tags returned by tagsFor
+// from each of the clients. If set, collected is called with the number of
+// posts stored for each tag.
+func (c *Collector) collectTags(ctx context.Context, clients []*mastodon.Client, tagsFor func(*mastodon.Client) []string, collected func(cl *mastodon.Client, tag string, n int)) (*RunSummary, error) {
+	summary := c.eachServer(ctx, clients, c.serverTimeout, func(ctx context.Context, cl *mastodon.Client) (int, error) {
 		log.Info("collecting from server: ", cl.Config.Server)
 		timelineFeed := client.ServerFeed(ctx, cl)
 
-		for _, tag := range tagNames {
-			items, err := timelineFeed(tag)
+		var (
+			total    int
+			firstErr error
+		)
+
+		for _, tag := range tagsFor(cl) {
+			n, err := c.collectTag(cl, tag, timelineFeed)
+			total += n
+
+			if collected != nil {
+				collected(cl, tag, n)
+			}
 
 			if err != nil {
-				log.Errorf("error collecting data: %v\n", err)
-				return err
+				log.Errorf("error collecting '%s' from %s: %v\n", tag, cl.Config.Server, err)
+
+				if firstErr == nil {
+					firstErr = err
+				}
 			}
+		}
+
+		return total, firstErr
+	})
+
+	log.Info(summary)
+	return summary, summary.Err()
+}
+
+// eachServer calls fn for each client using a bounded pool of workers, and
+// summarizes the results. Each call is bounded by the timeout, if non-zero.
+func (c *Collector) eachServer(ctx context.Context, clients []*mastodon.Client, timeout time.Duration, fn func(context.Context, *mastodon.Client) (int, error)) *RunSummary {
+	summary := &RunSummary{
+		Started: time.Now(),
+		Servers: make([]ServerResult, len(clients)),
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < c.workers && w < len(clients); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				cl := clients[i]
+				started := time.Now()
+
+				serverCtx, cancel := ctx, context.CancelFunc(func() {})
+				if timeout > 0 {
+					serverCtx, cancel = context.WithTimeout(ctx, timeout)
+				}
 
-			for _, item := range items {
-				{
-					var exists bool
-
-					err := c.db.Get(&exists, "SELECT 1 FROM posts WHERE uri = ?", item.URI)
-
-					if err != nil && err != sql.ErrNoRows {
-						return err
-					}
-
-					if exists {
-						log.Debug("skipping row")
-						continue
-					}
-
-					postRes := sqlx.MustExec(c.db, `
-				INSERT INTO posts (
-					post_id,
-					account_id,
-					server,
-					uri,
-					lang,
-					content_html,
-					created_at
-				) VALUES (
-					?, ?, ?, ?, ?, ?, ?
-				);`,
-						item.ID,
-						item.Account.ID,
-						cl.Config.Server,
-						item.URI,
-						coalesceString("en", item.Language),
-						item.Content,
-						item.CreatedAt,
-					)
-
-					postID, err := postRes.LastInsertId()
-
-					if err != nil {
-						return err
-					}
-
-					log.Debug("inserted post")
-
-					for _, tag := range item.Tags {
-						sqlx.MustExec(c.db, `INSERT OR IGNORE INTO tags (name) VALUES (?);`, tag.Name)
-
-						sqlx.MustExec(c.db, `
-					INSERT INTO posts_tags (
-						post_id,
-						tag_id
-					) VALUES (
-						?, (SELECT id FROM tags WHERE name = ?)
-					);`, postID, tag.Name)
-					}
+				n, err := fn(serverCtx, cl)
+				cancel()
+
+				result := ServerResult{
+					Server:   cl.Config.Server,
+					Posts:    n,
+					Duration: time.Since(started),
+					Err:      err,
 				}
+
+				c.record(result, started)
+				summary.Servers[i] = result
 			}
+		}()
+	}
+
+	for i := range clients {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	summary.Duration = time.Since(summary.Started)
+	return summary
+}
+
+// record accumulates the result of collecting from a server in its health
+// and the collector's metrics.
+func (c *Collector) record(r ServerResult, at time.Time) {
+	c.health.record(r, at)
+	c.metrics.recordRun(r)
+}
+
+// collectTag fetches any posts for tag that are newer than the stored cursor,
+// and advances the cursor after each page is stored. It returns the number of
+// posts stored.
+func (c *Collector) collectTag(cl *mastodon.Client, tag string, timelineFeed client.TagTimeline) (int, error) {
+	server := cl.Config.Server
+	cursor, err := c.Cursor(server, tag)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if cursor == "" {
+		// first run for this tag; start from the latest page
+		items, err := timelineFeed(tag, nil)
+
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := c.insertStatuses(server, tag, items)
+
+		if err != nil {
+			return n, err
+		}
+
+		return n, c.SaveCursor(server, tag, client.LatestID(cursor, items))
+	}
+
+	var total int
+
+	for page := 0; page < maxPages; page++ {
+		if rl, ok := client.Budget(cl); ok && rl.Low(time.Now(), budgetReserve) {
+			log.Infof("pausing '%s' on %s, %d of %d requests remain until %s",
+				tag, server, rl.Remaining, rl.Limit, rl.Reset.Format(time.Kitchen))
+			return total, nil
+		}
+
+		items, err := timelineFeed(tag, &mastodon.Pagination{MinID: cursor})
+
+		if err != nil {
+			return total, err
 		}
+
+		latest := client.LatestID(cursor, items)
+
+		if latest == cursor {
+			// caught up
+			return total, nil
+		}
+
+		n, err := c.insertStatuses(server, tag, items)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+
+		if err := c.SaveCursor(server, tag, latest); err != nil {
+			return total, err
+		}
+
+		cursor = latest
 	}
-	return nil
+
+	log.Debugf("page limit reached for tag '%s' on %s", tag, server)
+	return total, nil
 }
 
-// Start will run this collector in a loop. It will shut down
-// when Stop() is called, or if Collect() function returns an error.
-func (c *Collector) Start(ctx context.Context, tagNames []string) {
-	log.Infof("collector started. will refresh every %s", c.sampleRate)
-	c.stop = make(chan struct{}, 1)
+// Backfill pages backwards through the timeline of each tag until posts
+// created before the cutoff are reached, importing them to the database in the
+// same way as Collect.
+//
+// Servers are backfilled concurrently. It returns a summary of the run, and
+// an error only if backfill failed for every server.
+func (c *Collector) Backfill(ctx context.Context, tagNames []string, cutoff time.Time) (*RunSummary, error) {
+	return c.backfill(ctx, c.clients, tagNames, cutoff)
+}
 
-	go func() {
-		ctx, cancel := context.WithCancel(ctx)
-		tick := time.NewTicker(c.sampleRate)
+// backfill collects posts for the tags from each of the clients, back to the
+// cutoff. Paging back through a busy tag may wait out several rate limit
+// windows, so it is not bounded by the server timeout.
+func (c *Collector) backfill(ctx context.Context, clients []*mastodon.Client, tagNames []string, cutoff time.Time) (*RunSummary, error) {
+	summary := c.eachServer(ctx, clients, 0, func(ctx context.Context, cl *mastodon.Client) (int, error) {
+		log.Infof("backfilling from server: %s (since %s)", cl.Config.Server, cutoff.Format(time.RFC3339))
+		timelineFeed := client.ServerFeed(ctx, cl)
 
-		defer tick.Stop()
-		defer cancel()
+		var total int
 
-		for {
-			log.Debug("collector run starting")
+		for _, tag := range tagNames {
+			n, err := c.backfillTag(cl.Config.Server, tag, cutoff, timelineFeed)
+			total += n
 
-			if err := c.Collect(ctx, tagNames); err != nil {
-				log.Errorf("collector failed with error: %v", err)
-				tick.Stop()
-				c.Stop()
+			if err != nil {
+				log.Errorf("error backfilling '%s' from %s: %v\n", tag, cl.Config.Server, err)
+				return total, err
 			}
+		}
+
+		return total, nil
+	})
+
+	log.Info(summary)
+	return summary, summary.Err()
+}
+
+// backfillTag fetches pages of posts for tag, starting from the latest page,
+// until a page containing posts older than the cutoff is reached.
+func (c *Collector) backfillTag(server, tag string, cutoff time.Time, timelineFeed client.TagTimeline) (int, error) {
+	cursor, err := c.Cursor(server, tag)
 
-			select {
-			case <-tick.C:
-				// proceed through next iteration
-			case <-c.stop:
-				log.Debug("collector shutting down..")
-				c.stop <- struct{}{} // signals back to Stop()
-				return
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		pg     *mastodon.Pagination
+		latest mastodon.ID
+		total  int
+	)
+
+	for {
+		// the feed updates pg with the pagination of the next page
+		var requested mastodon.ID
+		if pg != nil {
+			requested = pg.MaxID
+		}
+
+		items, err := timelineFeed(tag, pg)
+
+		if err != nil {
+			return total, err
+		}
+
+		if len(items) == 0 {
+			break
+		}
+
+		var (
+			recent []*mastodon.Status
+			done   bool
+		)
+
+		for _, item := range items {
+			if item.CreatedAt.Before(cutoff) {
+				done = true
+				continue
 			}
+			recent = append(recent, item)
+		}
+
+		n, err := c.insertStatuses(server, tag, recent)
+		total += n
+
+		if err != nil {
+			return total, err
 		}
-	}()
+
+		latest = client.LatestID(latest, items)
+
+		oldest := client.OldestID(items)
+
+		if done || (requested != "" && oldest == requested) {
+			break
+		}
+
+		pg = &mastodon.Pagination{MaxID: oldest}
+	}
+
+	log.Infof("backfilled %d posts for tag '%s' on %s", total, tag, server)
+
+	if cursor == "" {
+		// later runs of Collect continue from the newest backfilled post
+		return total, c.SaveCursor(server, tag, latest)
+	}
+	return total, nil
 }
 
-// Stop will shutdown the collector, waiting a predetermined time for a graceful
-// shutdown, after which it will return.
-func (c *Collector) Stop() {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second))
+// insertStatuses stores any of the provided statuses which have not been seen
+// before, along with their tags, and records that the server has seen each
+// of them. Posts are counted in the collector's metrics
+// by server and the tag they were collected for. It returns the number of
+// posts stored.
+func (c *Collector) insertStatuses(server, tag string, items []*mastodon.Status) (int, error) {
+	posts, skipped, err := c.InsertStatuses(server, items)
+	c.metrics.recordPosts(server, tag, len(posts), skipped)
+
+	if err != nil || !c.events.active() {
+		return len(posts), err
+	}
 
-	c.stop <- struct{}{} // signal main routine to stop
+	var tags []string
 
-	select {
-	case <-c.stop: // main routine signaled back
-		log.Debug("collector finished")
-	case <-ctx.Done():
-		log.Debug("shutting down now")
+	for _, p := range posts {
+		names := p.TagList.names()
+
+		c.events.publish(Event{
+			Type: EventPost,
+			Tags: names,
+			Data: PostEvent{Status: p, Server: server},
+		})
+		tags = append(tags, names...)
 	}
 
-	cancel()
+	if len(tags) > 0 {
+		c.publishCounts(tags)
+	}
+	return len(posts), nil
+}
+
+// InsertStatuses stores any of the statuses which have not been seen before,
+// along with their tags, and records that the server has seen each of them.
+// Boosts are stored as the original post, and the account which boosted it.
+// Statuses are stored in a single transaction, so none are stored on error.
+func (s *sqlStore) InsertStatuses(server string, items []*mastodon.Status) ([]*Status, int, error) {
+	var (
+		inserted []*Status
+		skipped  int
+	)
+
+	err := s.write(func(w *writeTx) error {
+		for _, item := range items {
+			post, boost := item, (*mastodon.Status)(nil)
+
+			if item.Reblog != nil {
+				post, boost = item.Reblog, item
+			}
+
+			status, err := w.insertPost(server, post, boost)
+
+			if err != nil {
+				return err
+			}
+
+			if status != nil {
+				inserted = append(inserted, status)
+				log.Debug("inserted post")
+				continue
+			}
+
+			log.Debug("skipping row")
+			skipped++
+
+			var existingID int64
+
+			if err := w.get(&existingID, "SELECT id FROM posts WHERE uri = ?", post.URI); err != nil {
+				return err
+			}
+
+			// the post may have been seen by another server, or boosted
+			if err := w.recordSighting(existingID, server, post.ID); err != nil {
+				return err
+			}
+
+			if boost != nil {
+				err = w.recordBoost(existingID, server, boost)
+			} else {
+				err = w.seenDirectly(existingID)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return inserted, skipped, nil
 }
 
-// Report generates a list of posts matching one or more of the provided tagNames.
-// It returns an error if the underlying SQL query fails.
-func (c *Collector) Report(ctx context.Context, tagNames []string) ([]*Status, error) {
-	var results []*Status
+// insertPost stores a post, with its tags and details, and returns it as
+// stored. It returns nil if a post with the same URI is already stored.
+func (w *writeTx) insertPost(server string, post, boost *mastodon.Status) (*Status, error) {
+	var (
+		acct    = canonicalAcct(server, post.Account)
+		acctArg any
+	)
+
+	if acct != "" {
+		acctArg = acct
+	}
 
-	query, args, err := sqlx.In(`
+	var postID int64
+
+	err := w.get(&postID, `
+		INSERT INTO posts (
+			post_id,
+			account_id,
+			acct,
+			server,
+			uri,
+			origin,
+			lang,
+			content_html,
+			content_text,
+			created_at,
+			in_reply_to_id,
+			in_reply_to_account_id,
+			spoiler_text,
+			sensitive,
+			visibility,
+			replies_count,
+			reblogs_count,
+			favourites_count,
+			via_boost
+		) VALUES (
+			?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?
+		)
+		ON CONFLICT (uri) DO NOTHING
+		RETURNING id;`,
+		post.ID,
+		post.Account.ID,
+		acctArg,
+		server,
+		post.URI,
+		originHost(post.URI),
+		coalesceString("en", post.Language),
+		post.Content,
+		plainText(post.Content),
+		post.CreatedAt,
+		statusID(post.InReplyToID),
+		statusID(post.InReplyToAccountID),
+		post.SpoilerText,
+		post.Sensitive,
+		coalesceString("public", post.Visibility),
+		post.RepliesCount,
+		post.ReblogsCount,
+		post.FavouritesCount,
+		boost != nil,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if acct != "" {
+		if err := w.upsertAccount(acct, post.Account); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.recordSighting(postID, server, post.ID); err != nil {
+		return nil, err
+	}
+
+	if err := w.insertDetails(postID, server, post); err != nil {
+		return nil, err
+	}
+
+	if err := w.recordEngagement(postID, post); err != nil {
+		return nil, err
+	}
+
+	if boost != nil {
+		if err := w.recordBoost(postID, server, boost); err != nil {
+			return nil, err
+		}
+	}
+
+	var names []string
+
+	for _, tag := range post.Tags {
+		names = append(names, tag.Name)
+	}
+
+	if err := w.addTags(postID, post.Tags); err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		ID:              strconv.FormatInt(postID, 10),
+		URI:             post.URI,
+		Account:         acct,
+		Origin:          originHost(post.URI),
+		Reach:           1,
+		Language:        coalesceString("en", post.Language),
+		Content:         post.Content,
+		TagList:         tagList(strings.Join(names, ",")),
+		CreatedAt:       sqliteDatetime(post.CreatedAt),
+		InReplyToID:     statusID(post.InReplyToID),
+		SpoilerText:     post.SpoilerText,
+		Sensitive:       post.Sensitive,
+		Visibility:      coalesceString("public", post.Visibility),
+		RepliesCount:    post.RepliesCount,
+		ReblogsCount:    post.ReblogsCount,
+		FavouritesCount: post.FavouritesCount,
+		MediaCount:      len(post.MediaAttachments),
+		ViaBoost:        boost != nil,
+	}, nil
+}
+
+// publishCounts publishes the number of posts stored for each of the tags.
+func (c *Collector) publishCounts(tags []string) {
+	counts, err := c.TagCounts(context.Background(), uniqueStrings(tags))
+
+	if err != nil {
+		log.Warnf("unable to count posts for tags: %v", err)
+		return
+	}
+
+	for _, tc := range counts {
+		c.events.publish(Event{Type: EventCount, Tags: []string{tc.Tag}, Data: tc})
+	}
+}
+
+// TagCounts returns the number of posts stored with each of the tags.
+func (s *sqlStore) TagCounts(ctx context.Context, tags []string) ([]TagCount, error) {
+	var results []TagCount
+
+	err := s.selectContext(ctx, &results, `
 		SELECT
-		created_at, uri, lang, content_html as content,
-		(
-			SELECT group_concat(tt.name)
-			FROM posts_tags ptt
-			JOIN tags tt ON tt.id = ptt.tag_id
-			WHERE post_id = p.id
-			ORDER BY tt.name
-		) tag_list
+			t.name tag,
+			COUNT(*) count
 		FROM
-			posts p
+			tags t
 		INNER JOIN
-			posts_tags pt ON pt.post_id = p.id
-		INNER JOIN
-			tags tag ON tag.id = pt.tag_id
+			posts_tags pt ON pt.tag_id = t.id
 		WHERE
-			tag.name IN (?)
-		AND
-			created_at > date('now', '-2 days')
-		ORDER BY created_at DESC;
-	`, tagNames)
+			t.name IN (?)
+		GROUP BY tag
+		ORDER BY tag;
+	`, tags)
 
-	if err != nil {
-		return nil, err
+	return results, err
+}
+
+// Cursor returns the ID of the newest post stored for the tag on a server,
+// or an empty ID if the tag has not been collected from that server.
+func (s *sqlStore) Cursor(server, tag string) (mastodon.ID, error) {
+	var lastID mastodon.ID
+
+	err := s.get(&lastID, "SELECT last_id FROM timeline_cursors WHERE server = ? AND tag = ?", server, strings.ToLower(tag))
+
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
 	}
 
-	query = c.db.Rebind(query)
+	return lastID, nil
+}
 
-	if err := c.db.Select(&results, query, args...); err != nil {
-		return nil, err
+// SaveCursor records the ID of the newest post stored for the tag on a server.
+func (s *sqlStore) SaveCursor(server, tag string, lastID mastodon.ID) error {
+	if lastID == "" {
+		return nil
+	}
+
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	_, err := s.exec(`
+		INSERT INTO timeline_cursors (server, tag, last_id, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (server, tag) DO UPDATE SET
+			last_id = excluded.last_id,
+			updated_at = excluded.updated_at;`,
+		server, strings.ToLower(tag), lastID, time.Now().UTC(),
+	)
+
+	return err
+}
+
+// Start will run this collector in a loop. It will shut down when Stop() is
+// called. Failed runs are logged, and collection continues on the next tick.
+func (c *Collector) Start(ctx context.Context, tagNames []string) {
+	c.StartWatches(ctx, []Watch{{Tags: tagNames, Clients: c.clients}})
+}
+
+// Stop will shutdown the collector, waiting a predetermined time for a graceful
+// shutdown, after which it will return.
+func (c *Collector) Stop() {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second))
+
+	c.stop <- struct{}{} // signal main routine to stop
+
+	select {
+	case <-c.stop: // main routine signaled back
+		log.Debug("collector finished")
+	case <-ctx.Done():
+		log.Debug("shutting down now")
 	}
-	return results, nil
+
+	cancel()
 }
 
 func coalesceString(defaultVal, val string) string {
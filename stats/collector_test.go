@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestBackfill(t *testing.T) {
+	now := time.Now().UTC()
+
+	testCases := []struct {
+		name     string
+		cutoff   time.Time
+		expected int
+	}{
+		{name: "all pages", cutoff: now.Add(-24 * time.Hour), expected: 100},
+		{name: "stops at cutoff", cutoff: now.Add(-69*time.Minute - 30*time.Second), expected: 70},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 100 posts, one a minute, served newest first in pages of 20
+			// linked as a Mastodon server does
+			var ts *httptest.Server
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				maxID := 101
+				if v := r.URL.Query().Get("max_id"); v != "" {
+					maxID, _ = strconv.Atoi(v)
+				}
+
+				var page []*mastodon.Status
+				for id := maxID - 1; id > 0 && len(page) < 20; id-- {
+					page = append(page, &mastodon.Status{
+						ID:        mastodon.ID(strconv.Itoa(id)),
+						URI:       fmt.Sprintf("https://a.example/%d", id),
+						CreatedAt: now.Add(-time.Duration(100-id) * time.Minute),
+					})
+				}
+
+				if len(page) > 0 {
+					next := ts.URL + r.URL.Path + "?max_id=" + string(page[len(page)-1].ID)
+					prev := ts.URL + r.URL.Path + "?min_id=" + string(page[0].ID)
+					w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="prev"`, next, prev))
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(page)
+			}))
+			defer ts.Close()
+
+			c := newTestCollector(t)
+			c.clients = []*mastodon.Client{client.NewClient(&mastodon.Config{Server: ts.URL})}
+
+			summary, err := c.Backfill(context.Background(), []string{"outage"}, tc.cutoff)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Posts() != tc.expected {
+				t.Errorf("expected posts to match (%v / %v)\n", tc.expected, summary.Posts())
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ivan3bx/proma/client"
+)
+
+//go:embed web/templates/*.html web/static/*
+var webFiles embed.FS
+
+// dashboardRanges are the periods which may be shown on the dashboard,
+// with the interval used to bucket posts.
+var dashboardRanges = map[string]struct {
+	Period   time.Duration
+	Interval string
+	Window   int
+}{
+	"2h":  {2 * time.Hour, IntervalMinute, 15},
+	"24h": {24 * time.Hour, IntervalHour, 6},
+	"7d":  {7 * 24 * time.Hour, IntervalDay, 3},
+}
+
+// dashboardPost is a post with content sanitized for display.
+type dashboardPost struct {
+	URI       string         `json:"uri"`
+	Language  string         `json:"lang"`
+	HTML      string         `json:"html"`
+	TagList   tagList        `json:"tag_list"`
+	CreatedAt sqliteDatetime `json:"created_at"`
+}
+
+// dashboardData is refreshed periodically by the dashboard page.
+type dashboardData struct {
+	Tags        []string           `json:"tags"`
+	Range       string             `json:"range"`
+	Interval    string             `json:"interval"`
+	Trends      []TagTrend         `json:"trends"`
+	Posts       []dashboardPost    `json:"posts"`
+	Servers     []ServerHealth     `json:"servers"`
+	RateLimits  []client.RateLimit `json:"rate_limits"`
+	Cooccurring []TagCount         `json:"cooccurring"`
+	Posters     []Poster           `json:"posters"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// registerDashboard adds the dashboard page, its data and static assets.
+func (s *Server) registerDashboard(e *gin.Engine) {
+	tmpl := template.Must(template.ParseFS(webFiles, "web/templates/*.html"))
+	e.SetHTMLTemplate(tmpl)
+
+	static, _ := fs.Sub(webFiles, "web/static")
+	e.StaticFS("/static", http.FS(static))
+
+	e.GET("/", s.dashboard)
+	e.GET("/dashboard.json", s.dashboardData)
+}
+
+func (s *Server) dashboard(c *gin.Context) {
+	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"Tags": s.tagNames,
+	})
+}
+
+func (s *Server) dashboardData(c *gin.Context) {
+	name := c.DefaultQuery("range", "24h")
+	r, ok := dashboardRanges[name]
+
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown range: " + name})
+		return
+	}
+
+	now := time.Now()
+	opts := ReportOptions{Tags: s.tagNames, From: now.Add(-r.Period)}
+
+	trends, err := s.collector.TagTrends(c, TrendOptions{
+		ReportOptions: opts,
+		Interval:      r.Interval,
+		Window:        r.Window,
+		Threshold:     3,
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	latest := opts
+	latest.Limit = 20
+
+	statuses, err := s.collector.Report(c, latest)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cooccurring, err := s.collector.CooccurringTags(c, opts, 15)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	posters, err := s.collector.TopPosters(c, opts, 5)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts := make([]dashboardPost, 0, len(statuses))
+
+	for _, st := range statuses {
+		posts = append(posts, dashboardPost{
+			URI:       st.URI,
+			Language:  st.Language,
+			HTML:      sanitizeHTML(st.Content),
+			TagList:   st.TagList,
+			CreatedAt: st.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, dashboardData{
+		Tags:        s.tagNames,
+		Range:       name,
+		Interval:    r.Interval,
+		Trends:      trends,
+		Posts:       posts,
+		Servers:     s.collector.Health(),
+		RateLimits:  client.DefaultTransport.Budgets(),
+		Cooccurring: cooccurring,
+		Posters:     posters,
+		UpdatedAt:   now,
+	})
+}
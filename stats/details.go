@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"fmt"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// statusID returns an optional status or account ID of a status, which
+// go-mastodon decodes without a type, or an empty string if it is not set.
+func statusID(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// insertDetails stores the media attachments and mentions of a post.
+func (w *writeTx) insertDetails(postID int64, server string, status *mastodon.Status) error {
+	for i, m := range status.MediaAttachments {
+		url := coalesceString(m.RemoteURL, m.URL)
+
+		if _, err := w.exec(`
+			INSERT INTO post_media (post_id, position, type, url, preview_url, description)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING;`,
+			postID, i, m.Type, url, m.PreviewURL, m.Description,
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range status.Mentions {
+		acct := canonicalAcct(server, mastodon.Account{Acct: m.Acct})
+
+		if acct == "" {
+			continue
+		}
+
+		if _, err := w.exec(`
+			INSERT INTO post_mentions (post_id, acct, url)
+			VALUES (?, ?, ?)
+			ON CONFLICT DO NOTHING;`,
+			postID, acct, m.URL,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordBoost records the account which boosted a post, as seen on a server.
+func (w *writeTx) recordBoost(postID int64, server string, boost *mastodon.Status) error {
+	acct := canonicalAcct(server, boost.Account)
+
+	if acct == "" {
+		return nil
+	}
+
+	if err := w.upsertAccount(acct, boost.Account); err != nil {
+		return err
+	}
+
+	_, err := w.exec(`
+		INSERT INTO boosts (post_id, acct, server, local_id, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING;`,
+		postID, acct, server, boost.ID, boost.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// seenDirectly records that a post collected as the target of a boost has
+// since been collected by itself.
+func (w *writeTx) seenDirectly(postID int64) error {
+	_, err := w.exec("UPDATE posts SET via_boost = ? WHERE id = ? AND via_boost", false, postID)
+	return err
+}
@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestPostDetails(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC()
+	outage := []mastodon.Tag{{Name: "outage"}}
+
+	original := &mastodon.Status{
+		ID:               "10",
+		URI:              "https://a.example/1",
+		Account:          mastodon.Account{Acct: "ann@a.example", Username: "ann"},
+		Tags:             outage,
+		CreatedAt:        now.Add(-3 * time.Hour),
+		ReblogsCount:     5,
+		MediaAttachments: []mastodon.Attachment{{Type: "image", URL: "https://files.example/1.png", Description: "map"}},
+		Mentions:         []mastodon.Mention{{Acct: "bob", URL: "https://mastodon.social/@bob"}},
+	}
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{ID: "11", URI: "https://mastodon.social/boosts/11", Account: mastodon.Account{Acct: "cat", Username: "cat"}, Reblog: original, CreatedAt: now.Add(-time.Hour)},
+		&mastodon.Status{ID: "12", URI: "https://a.example/2", Tags: outage, CreatedAt: now.Add(-2 * time.Hour), InReplyToID: "10", FavouritesCount: 1},
+		&mastodon.Status{ID: "13", URI: "https://a.example/3", Tags: outage, CreatedAt: now.Add(-time.Hour), SpoilerText: "power cut", Sensitive: true, RepliesCount: 2},
+	)
+
+	testCases := []struct {
+		name     string
+		opts     ReportOptions
+		expected []string
+	}{
+		{
+			name:     "all posts",
+			expected: []string{"3", "2", "1"},
+		},
+		{
+			name:     "no boosts, replies or sensitive posts",
+			opts:     ReportOptions{ExcludeBoosts: true, ExcludeReplies: true, ExcludeSensitive: true},
+			expected: nil,
+		},
+		{
+			name:     "no replies",
+			opts:     ReportOptions{ExcludeReplies: true},
+			expected: []string{"3", "1"},
+		},
+		{
+			name:     "by engagement",
+			opts:     ReportOptions{Sort: SortEngagement},
+			expected: []string{"1", "3", "2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := c.Report(context.Background(), tc.opts)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, r.URI[len("https://a.example/"):])
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected posts to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+
+	results, err := c.Report(context.Background(), ReportOptions{Sort: SortEngagement, Limit: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := results[0]; !p.ViaBoost || p.MediaCount != 1 || p.Account != "ann@a.example" || string(p.Mentions) != "bob@mastodon.social" {
+		t.Errorf("expected boosted post with details, was: %+v\n", p)
+	}
+
+	var booster string
+	if err := testStore(c).get(&booster, "SELECT acct FROM boosts"); err != nil || booster != "cat@mastodon.social" {
+		t.Errorf("expected boost to be recorded, was: %s %v\n", booster, err)
+	}
+
+	// seen by itself on another server
+	insertTestStatuses(t, c, "https://hachyderm.io", original)
+
+	if results, _ := c.Report(context.Background(), ReportOptions{ExcludeBoosts: true}); len(results) != 3 {
+		t.Errorf("expected post collected directly to be included, was: %d posts\n", len(results))
+	}
+
+	if _, err := c.Report(context.Background(), ReportOptions{Sort: SortEngagement, Cursor: results[0].Cursor()}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected cursor to be rejected when sorting by engagement, was: %v\n", err)
+	}
+}
@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// PostGrowth is a post and the growth in its engagement since it was first
+// collected.
+type PostGrowth struct {
+	Tag       string         `json:"tag" db:"tag"`
+	URI       string         `json:"uri" db:"uri"`
+	Account   string         `json:"account,omitempty" db:"acct"`
+	CreatedAt sqliteDatetime `json:"created_at" db:"created_at"`
+
+	// Replies, Reblogs and Favourites are the increase in each count between
+	// the first and latest snapshot of the post.
+	Replies    int64 `json:"replies" db:"replies"`
+	Reblogs    int64 `json:"reblogs" db:"reblogs"`
+	Favourites int64 `json:"favourites" db:"favourites"`
+
+	// Growth is the total increase in replies, boosts and favourites.
+	Growth int64 `json:"growth" db:"growth"`
+
+	// Snapshots is the number of times the counts were captured.
+	Snapshots int `json:"snapshots" db:"snapshots"`
+}
+
+// recordEngagement stores a snapshot of the reply, boost and favourite
+// counts of a post.
+func (w *writeTx) recordEngagement(postID int64, status *mastodon.Status) error {
+	_, err := w.exec(`
+		INSERT INTO post_engagement (post_id, captured_at, replies_count, reblogs_count, favourites_count)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING;`,
+		postID, time.Now().UTC(), status.RepliesCount, status.ReblogsCount, status.FavouritesCount,
+	)
+	return err
+}
+
+// TopGrowth returns up to limit posts whose engagement grew the most since
+// they were collected for each tag, ordered by tag and then by growth. Counts
+// are captured when posts are collected and each time they are rechecked, so
+// posts which have not been rechecked have not grown.
+func (s *sqlStore) TopGrowth(ctx context.Context, opts ReportOptions, limit int) ([]PostGrowth, error) {
+	var results []PostGrowth
+
+	where, args := TrendOptions{ReportOptions: opts}.tagWhere()
+	args = append(args, limit)
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			tag, uri, acct, created_at, replies, reblogs, favourites,
+			replies + reblogs + favourites growth, snapshots
+		FROM (
+			SELECT
+				t.name tag,
+				p.id,
+				p.uri,
+				COALESCE(p.acct, '') acct,
+				p.created_at,
+				p.replies_count - e.replies_count replies,
+				p.reblogs_count - e.reblogs_count reblogs,
+				p.favourites_count - e.favourites_count favourites,
+				(SELECT COUNT(*) FROM post_engagement WHERE post_id = p.id) snapshots,
+				ROW_NUMBER() OVER (
+					PARTITION BY t.name
+					ORDER BY `+engagement+` - (e.replies_count + e.reblogs_count + e.favourites_count) DESC, p.created_at DESC, p.id DESC
+				) AS position
+			FROM
+				posts p
+			INNER JOIN
+				post_engagement e ON e.post_id = p.id
+				AND e.captured_at = (SELECT MIN(captured_at) FROM post_engagement WHERE post_id = p.id)
+			INNER JOIN
+				posts_tags pt ON pt.post_id = p.id
+			INNER JOIN
+				tags t ON t.id = pt.tag_id
+			WHERE
+				`+strings.Join(where, " AND ")+`
+		) ranked
+		WHERE position <= ? AND replies + reblogs + favourites > 0
+		ORDER BY tag, growth DESC, created_at DESC, id DESC;
+	`, args...)
+
+	return results, err
+}
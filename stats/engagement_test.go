@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestTopGrowth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch strings.TrimPrefix(r.URL.Path, "/api/v1/statuses/") {
+		case "1":
+			fmt.Fprint(w, `{"id":"1","content":"<p>one</p>","replies_count":1,"reblogs_count":2,"favourites_count":3}`)
+		case "2":
+			fmt.Fprint(w, `{"id":"2","content":"<p>two</p>","replies_count":4,"reblogs_count":30,"favourites_count":50}`)
+		case "3":
+			fmt.Fprint(w, `{"id":"3","content":"<p>three</p>","reblogs_count":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := newTestCollector(t)
+	c.clients = []*mastodon.Client{client.NewClient(&mastodon.Config{Server: ts.URL})}
+
+	now := time.Now().UTC()
+	outage := []mastodon.Tag{{Name: "outage"}}
+
+	insertTestStatuses(t, c, ts.URL,
+		&mastodon.Status{ID: "1", URI: "https://a.example/1", Content: "<p>one</p>", Tags: outage, CreatedAt: now.Add(-time.Hour)},
+		&mastodon.Status{ID: "2", URI: "https://a.example/2", Content: "<p>two</p>", Tags: outage, CreatedAt: now.Add(-time.Hour), ReblogsCount: 20, FavouritesCount: 10},
+		&mastodon.Status{ID: "3", URI: "https://a.example/3", Content: "<p>three</p>", Tags: outage, CreatedAt: now.Add(-time.Hour), ReblogsCount: 1},
+	)
+
+	if _, err := c.Recheck(context.Background(), 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "posts which grew",
+			limit:    10,
+			expected: []string{"2:4/10/40:54", "1:1/2/3:6"},
+		},
+		{
+			name:     "limit per tag",
+			limit:    1,
+			expected: []string{"2:4/10/40:54"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := c.TopGrowth(context.Background(), ReportOptions{Tags: []string{"outage"}}, tc.limit)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, fmt.Sprintf("%s:%d/%d/%d:%d",
+					r.URI[len("https://a.example/"):], r.Replies, r.Reblogs, r.Favourites, r.Growth))
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected posts to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+
+	results, err := c.Report(context.Background(), ReportOptions{Sort: SortEngagement, Limit: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := results[0]; p.RepliesCount != 4 || p.ReblogsCount != 30 || p.FavouritesCount != 50 {
+		t.Errorf("expected counts of rechecked post, was: %+v\n", p)
+	}
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"strings"
+	"sync"
+)
+
+// Event types published by a collector.
+const (
+	EventPost  = "post"
+	EventCount = "count"
+)
+
+// eventBuffer is the number of events queued for each subscriber. Events
+// are dropped for subscribers which fall further behind.
+const eventBuffer = 64
+
+// Event is published by a collector as it stores posts. Post events carry
+// a PostEvent, and count events a TagCount with the total posts for a tag.
+type Event struct {
+	Type string
+	Tags []string
+	Data any
+}
+
+// PostEvent is a newly stored post, along with the server it was collected from.
+type PostEvent struct {
+	*Status
+	Server string `json:"server"`
+}
+
+// Matches returns true if the event concerns any of the tags. Every
+// event matches an empty list of tags.
+func (e Event) Matches(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, t := range tags {
+		for _, et := range e.Tags {
+			if strings.EqualFold(t, et) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// broker fans out events to subscribers without blocking the publisher.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// subscribe returns a channel receiving published events, and a function
+// which unsubscribes and closes the channel.
+func (b *broker) subscribe() (chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = map[chan Event]struct{}{}
+	}
+
+	ch := make(chan Event, eventBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// active returns true if there are any subscribers.
+func (b *broker) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers) > 0
+}
+
+func (b *broker) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// subscriber is too slow; drop the event
+		}
+	}
+}
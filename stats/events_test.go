@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestEventMatches(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tags   []string
+		expect bool
+	}{
+		{name: "no filter", tags: nil, expect: true},
+		{name: "matching tag", tags: []string{"power", "outage"}, expect: true},
+		{name: "matching case", tags: []string{"Outage"}, expect: true},
+		{name: "other tag", tags: []string{"power"}, expect: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := Event{Type: EventPost, Tags: []string{"outage", "london"}}
+
+			if actual := e.Matches(tc.tags); actual != tc.expect {
+				t.Errorf("expected match to be (%v / %v)\n", tc.expect, actual)
+			}
+		})
+	}
+}
+
+func TestBroker(t *testing.T) {
+	var b broker
+
+	if b.active() {
+		t.Fatal("expected broker without subscribers to be inactive")
+	}
+
+	ch, unsubscribe := b.subscribe()
+
+	for i := 0; i < eventBuffer+10; i++ {
+		b.publish(Event{Type: EventCount, Data: i})
+	}
+
+	if len(ch) != eventBuffer {
+		t.Errorf("expected queued events to match (%v / %v)\n", eventBuffer, len(ch))
+	}
+
+	unsubscribe()
+	unsubscribe()
+
+	if b.active() {
+		t.Error("expected broker to be inactive after unsubscribing")
+	}
+
+	// publishing without subscribers does not block
+	b.publish(Event{Type: EventCount})
+}
+
+func TestInsertStatusesPublishesEvents(t *testing.T) {
+	c := newTestCollector(t)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+	)
+
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+		&mastodon.Status{URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+	)
+
+	var received []Event
+
+	for len(events) > 0 {
+		received = append(received, <-events)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected events to match (%v / %v): %+v\n", 2, len(received), received)
+	}
+
+	post, ok := received[0].Data.(PostEvent)
+
+	if received[0].Type != EventPost || !ok || post.URI != "https://a.example/2" || post.Server != "https://mastodon.social" {
+		t.Errorf("unexpected post event: %+v\n", received[0])
+	}
+
+	count, ok := received[1].Data.(TagCount)
+
+	if received[1].Type != EventCount || !ok || count.Tag != "outage" || count.Count != 2 {
+		t.Errorf("unexpected count event: %+v\n", received[1])
+	}
+}
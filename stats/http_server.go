@@ -2,48 +2,175 @@ package stats
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 )
 
+// DefaultListen is the address a server listens on unless configured otherwise.
+const DefaultListen = "127.0.0.1:8080"
+
+// ServerOptions configure how a server listens and authenticates requests.
+// Zero values listen on DefaultListen over plain HTTP without authentication.
+type ServerOptions struct {
+	// Listen is a TCP address (host:port), or the path of a Unix socket
+	// prefixed with 'unix:'.
+	Listen string
+
+	// TLSCert and TLSKey are files containing a certificate and key to
+	// serve HTTPS. If SelfSigned is set instead, a certificate is
+	// generated when the server starts.
+	TLSCert    string
+	TLSKey     string
+	SelfSigned bool
+
+	// Requests must provide either the Username and Password with basic
+	// auth, or the BearerToken in an Authorization header, when set.
+	Username    string
+	Password    string
+	BearerToken string
+}
+
 type Server struct {
-	db  *sqlx.DB
-	web *http.Server
+	collector *Collector
+	tagNames  []string
+	opts      ServerOptions
+	web       *http.Server
 }
 
-func NewServer(ctx context.Context, db *sqlx.DB) *Server {
+// NewServer returns a server displaying a dashboard of the posts stored by
+// the collector for the provided tags.
+func NewServer(ctx context.Context, collector *Collector, tagNames []string, opts ServerOptions) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	e := gin.New()
-	e.GET("/", currentStats)
 
-	return &Server{
-		db: db,
+	if opts.Listen == "" {
+		opts.Listen = DefaultListen
+	}
+
+	s := &Server{
+		collector: collector,
+		tagNames:  tagNames,
+		opts:      opts,
 		web: &http.Server{
-			Addr:    "127.0.0.1:8080",
+			Addr:    opts.Listen,
 			Handler: e,
 			BaseContext: func(l net.Listener) context.Context {
 				return ctx
 			},
 		},
 	}
+
+	if opts.Username != "" || opts.BearerToken != "" {
+		e.Use(authenticate(opts))
+	}
+
+	s.registerDashboard(e)
+	s.registerAPI(e)
+	e.GET("/events", s.events)
+	e.GET("/metrics", s.metrics)
+	e.GET("/status", currentStats)
+
+	return s
 }
 
-func (s *Server) Start() {
-	log.Infof("http server is available at http://%s/\n", s.web.Addr)
+// Start listens on the configured address and serves requests in the
+// background. It returns an error if the server is unable to listen.
+func (s *Server) Start() error {
+	l, err := s.listen()
+
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	serve := s.web.Serve
+
+	if s.opts.TLSCert != "" || s.opts.SelfSigned {
+		if s.web.TLSConfig, err = s.tlsConfig(); err != nil {
+			l.Close()
+			return err
+		}
+
+		// certificates are loaded by tlsConfig
+		serve = func(l net.Listener) error {
+			return s.web.ServeTLS(l, "", "")
+		}
+		scheme = "https"
+	}
+
+	if strings.HasPrefix(s.opts.Listen, unixPrefix) {
+		log.Infof("http server is listening on %s\n", s.opts.Listen)
+	} else {
+		log.Infof("http server is available at %s://%s/\n", scheme, l.Addr())
+	}
 
 	go func() {
-		if err := s.web.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
+		if err := serve(l); errors.Is(err, http.ErrServerClosed) {
 			log.Info("http server stopped")
 		} else {
 			log.Errorf("http server stopped with error: %v\n", err)
 		}
 	}()
+
+	return nil
+}
+
+// unixPrefix marks a listen address as the path of a Unix socket.
+const unixPrefix = "unix:"
+
+// listen returns a listener for the configured address. A stale Unix
+// socket left by a previous server is replaced.
+func (s *Server) listen() (net.Listener, error) {
+	if !strings.HasPrefix(s.opts.Listen, unixPrefix) {
+		return net.Listen("tcp", s.opts.Listen)
+	}
+
+	path := strings.TrimPrefix(s.opts.Listen, unixPrefix)
+
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		os.Remove(path)
+	}
+
+	return net.Listen("unix", path)
+}
+
+// tlsConfig returns the TLS configuration for the configured certificate,
+// or a self-signed certificate for the listen address.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if s.opts.TLSCert != "" {
+		cert, err = tls.LoadX509KeyPair(s.opts.TLSCert, s.opts.TLSKey)
+	} else {
+		host, _, _ := net.SplitHostPort(s.opts.Listen)
+		cert, err = selfSignedCertificate(host, time.Now())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
 }
 
 func (s *Server) Shutdown() {
@@ -59,6 +186,46 @@ func (s *Server) Shutdown() {
 	s.web.Shutdown(ctx)
 }
 
+// keepAliveInterval is how often an idle event stream sends a ping, so
+// proxies and browsers do not close the connection.
+const keepAliveInterval = 30 * time.Second
+
+// events streams posts and tag counts as they are stored by the collector,
+// using Server-Sent Events. Events may be limited to the tags in 'tag'.
+func (s *Server) events(c *gin.Context) {
+	tags := queryList(c, "tag")
+	events, unsubscribe := s.collector.Subscribe()
+	defer unsubscribe()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// flush headers so clients see the stream open before the first event
+	c.SSEvent("ping", time.Now().UTC())
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if e.Matches(tags) {
+				c.SSEvent(e.Type, e.Data)
+			}
+			return true
+		case t := <-keepAlive.C:
+			c.SSEvent("ping", t.UTC())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
 func currentStats(c *gin.Context) {
 	c.JSON(200, gin.H{"stats": "ok"})
 }
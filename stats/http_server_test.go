@@ -0,0 +1,260 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestDashboard(t *testing.T) {
+	c := newTestCollector(t)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Content: "<p>out<script>x</script></p>", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: time.Now()},
+	)
+
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{})
+
+	testCases := []struct {
+		name        string
+		path        string
+		expectCode  int
+		expectMatch string
+	}{
+		{
+			name:        "page",
+			path:        "/",
+			expectCode:  200,
+			expectMatch: "#outage",
+		},
+		{
+			name:        "static",
+			path:        "/static/dashboard.js",
+			expectCode:  200,
+			expectMatch: "dashboard.json",
+		},
+		{
+			name:        "data",
+			path:        "/dashboard.json?range=2h",
+			expectCode:  200,
+			expectMatch: `"html":"\u003cp\u003eout\u003c/p\u003e"`,
+		},
+		{
+			name:       "unknown range",
+			path:       "/dashboard.json?range=1y",
+			expectCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.web.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.expectCode {
+				t.Fatalf("expected status to match (%v / %v)\n", tc.expectCode, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tc.expectMatch) {
+				t.Errorf("expected body to contain %q, was: %s\n", tc.expectMatch, rec.Body.String())
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.web.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard.json", nil))
+
+	var data struct {
+		Trends      []json.RawMessage `json:"trends"`
+		Posts       []json.RawMessage `json:"posts"`
+		Cooccurring []TagCount        `json:"cooccurring"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Trends) != 1 || len(data.Posts) != 1 || len(data.Cooccurring) != 1 || data.Cooccurring[0].Tag != "power" {
+		t.Errorf("unexpected dashboard data: %s\n", rec.Body.String())
+	}
+}
+
+func TestEvents(t *testing.T) {
+	c := newTestCollector(t)
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{})
+
+	ts := httptest.NewServer(s.web.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events?tag=outage")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected content type to match (%v / %v)\n", "text/event-stream", ct)
+	}
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "power"}}, CreatedAt: time.Now()},
+		&mastodon.Status{URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	var events []string
+
+	for len(events) < 2 && scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "event:") && line != "event:ping" {
+			scanner.Scan()
+			events = append(events, strings.TrimPrefix(line, "event:")+" "+scanner.Text())
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, received: %v\n", events)
+	}
+	if !strings.HasPrefix(events[0], "post ") || !strings.Contains(events[0], `"uri":"https://a.example/2"`) {
+		t.Errorf("unexpected post event: %s\n", events[0])
+	}
+	if expected := `count data:{"tag":"outage","count":1}`; events[1] != expected {
+		t.Errorf("expected count event to match (%v / %v)\n", expected, events[1])
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	c := newTestCollector(t)
+
+	testCases := []struct {
+		name       string
+		opts       ServerOptions
+		setAuth    func(r *http.Request)
+		expectCode int
+	}{
+		{
+			name:       "no auth required",
+			opts:       ServerOptions{},
+			setAuth:    func(r *http.Request) {},
+			expectCode: 200,
+		},
+		{
+			name:       "missing credentials",
+			opts:       ServerOptions{Username: "admin", Password: "secret"},
+			setAuth:    func(r *http.Request) {},
+			expectCode: 401,
+		},
+		{
+			name:       "basic auth",
+			opts:       ServerOptions{Username: "admin", Password: "secret"},
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("admin", "secret") },
+			expectCode: 200,
+		},
+		{
+			name:       "wrong password",
+			opts:       ServerOptions{Username: "admin", Password: "secret"},
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("admin", "guess") },
+			expectCode: 401,
+		},
+		{
+			name:       "bearer token",
+			opts:       ServerOptions{BearerToken: "abc123"},
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc123") },
+			expectCode: 200,
+		},
+		{
+			name:       "wrong token",
+			opts:       ServerOptions{BearerToken: "abc123"},
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc") },
+			expectCode: 401,
+		},
+		{
+			name:       "either credential",
+			opts:       ServerOptions{Username: "admin", Password: "secret", BearerToken: "abc123"},
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc123") },
+			expectCode: 200,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer(context.Background(), c, []string{"outage"}, tc.opts)
+
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			tc.setAuth(req)
+
+			rec := httptest.NewRecorder()
+			s.web.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectCode {
+				t.Errorf("expected status to match (%v / %v)\n", tc.expectCode, rec.Code)
+			}
+			if rec.Code == 401 && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestStartUnixSocket(t *testing.T) {
+	c := newTestCollector(t)
+	path := filepath.Join(t.TempDir(), "proma.sock")
+
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{Listen: "unix:" + path})
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown()
+
+	second := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{Listen: "unix:" + path})
+
+	if err := second.Start(); err == nil {
+		t.Error("expected error listening on a socket in use")
+	}
+
+	hc := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := hc.Get("http://proma/status")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("expected status to match (%v / %v)\n", 200, resp.StatusCode)
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := selfSignedCertificate("192.0.2.1", time.Now())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"localhost", "127.0.0.1", "192.0.2.1"} {
+		if err := leaf.VerifyHostname(host); err != nil {
+			t.Errorf("expected certificate to be valid for %s: %v\n", host, err)
+		}
+	}
+}
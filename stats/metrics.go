@@ -0,0 +1,182 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ivan3bx/proma/client"
+)
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// postSeries identifies posts counted for a tag collected from a server.
+type postSeries struct {
+	Server string
+	Tag    string
+}
+
+// runDurations accumulates the time spent collecting from a server.
+type runDurations struct {
+	Seconds float64
+	Count   int
+}
+
+// collectorMetrics are counters accumulated by a collector, which are not
+// otherwise recorded in the database or server health.
+type collectorMetrics struct {
+	mu        sync.Mutex
+	inserted  map[postSeries]int
+	skipped   map[postSeries]int
+	durations map[string]runDurations
+}
+
+func (m *collectorMetrics) recordPosts(server, tag string, inserted, skipped int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inserted == nil {
+		m.inserted = map[postSeries]int{}
+		m.skipped = map[postSeries]int{}
+	}
+
+	key := postSeries{Server: server, Tag: strings.ToLower(tag)}
+	m.inserted[key] += inserted
+	m.skipped[key] += skipped
+}
+
+func (m *collectorMetrics) recordRun(r ServerResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.durations == nil {
+		m.durations = map[string]runDurations{}
+	}
+
+	d := m.durations[r.Server]
+	d.Seconds += r.Duration.Seconds()
+	d.Count++
+	m.durations[r.Server] = d
+}
+
+// sample is a single value of a metric, with labels as name/value pairs.
+type sample struct {
+	Suffix string
+	Labels []string
+	Value  float64
+}
+
+// writeMetric writes a metric in the Prometheus text format, with samples
+// ordered by their labels.
+func writeMetric(w io.Writer, name, typ, help string, samples []sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return strings.Join(samples[i].Labels, "\x00") < strings.Join(samples[j].Labels, "\x00")
+	})
+
+	for _, s := range samples {
+		w.Write([]byte(name + s.Suffix))
+
+		if len(s.Labels) > 0 {
+			var pairs []string
+			for i := 0; i+1 < len(s.Labels); i += 2 {
+				pairs = append(pairs, s.Labels[i]+`="`+escapeLabel(s.Labels[i+1])+`"`)
+			}
+			w.Write([]byte("{" + strings.Join(pairs, ",") + "}"))
+		}
+
+		fmt.Fprintf(w, " %s\n", strconv.FormatFloat(s.Value, 'g', -1, 64))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// metrics writes collector, rate limit and database metrics in the
+// Prometheus text format.
+func (s *Server) metrics(c *gin.Context) {
+	var buf bytes.Buffer
+
+	s.collector.writeMetrics(&buf)
+
+	var (
+		remaining, limits []sample
+		apiErrors         []sample
+	)
+
+	for _, rl := range client.DefaultTransport.Budgets() {
+		remaining = append(remaining, sample{Labels: []string{"host", rl.Host}, Value: float64(rl.Remaining)})
+		limits = append(limits, sample{Labels: []string{"host", rl.Host}, Value: float64(rl.Limit)})
+	}
+
+	for _, ec := range client.DefaultTransport.Errors() {
+		apiErrors = append(apiErrors, sample{Labels: []string{"host", ec.Host, "code", strconv.Itoa(ec.Code)}, Value: float64(ec.Count)})
+	}
+
+	writeMetric(&buf, "proma_api_errors_total", "counter", "Error responses returned by Mastodon servers, by status code.", apiErrors)
+	writeMetric(&buf, "proma_rate_limit_remaining", "gauge", "Requests remaining before the rate limit of a server resets.", remaining)
+	writeMetric(&buf, "proma_rate_limit_limit", "gauge", "Requests allowed by a server within its rate limit period.", limits)
+
+	if size, err := s.collector.DatabaseSize(c); err == nil {
+		writeMetric(&buf, "proma_database_size_bytes", "gauge", "Size of the database storing collected posts.", []sample{{Value: float64(size)}})
+	}
+
+	c.Data(http.StatusOK, metricsContentType, buf.Bytes())
+}
+
+// writeMetrics writes the metrics accumulated by the collector and the
+// health of each server it collects from.
+func (c *Collector) writeMetrics(w io.Writer) {
+	var inserted, skipped, durations []sample
+
+	c.metrics.mu.Lock()
+
+	for key, n := range c.metrics.inserted {
+		labels := []string{"server", key.Server, "tag", key.Tag}
+		inserted = append(inserted, sample{Labels: labels, Value: float64(n)})
+		skipped = append(skipped, sample{Labels: labels, Value: float64(c.metrics.skipped[key])})
+	}
+
+	for server, d := range c.metrics.durations {
+		labels := []string{"server", server}
+		durations = append(durations,
+			sample{Suffix: "_sum", Labels: labels, Value: d.Seconds},
+			sample{Suffix: "_count", Labels: labels, Value: float64(d.Count)},
+		)
+	}
+
+	c.metrics.mu.Unlock()
+
+	var runs, failures, consecutive, lastSuccess []sample
+
+	for _, h := range c.Health() {
+		labels := []string{"server", h.Server}
+		runs = append(runs, sample{Labels: labels, Value: float64(h.Runs)})
+		failures = append(failures, sample{Labels: labels, Value: float64(h.Failures)})
+		consecutive = append(consecutive, sample{Labels: labels, Value: float64(h.ConsecutiveFailures)})
+
+		if !h.LastSuccess.IsZero() {
+			lastSuccess = append(lastSuccess, sample{Labels: labels, Value: float64(h.LastSuccess.Unix())})
+		}
+	}
+
+	writeMetric(w, "proma_posts_inserted_total", "counter", "Posts stored, by server and the tag they were collected for.", inserted)
+	writeMetric(w, "proma_posts_skipped_total", "counter", "Posts already stored, by server and the tag they were collected for.", skipped)
+	writeMetric(w, "proma_collection_duration_seconds", "summary", "Time spent collecting from a server.", durations)
+	writeMetric(w, "proma_collection_runs_total", "counter", "Collection runs for a server.", runs)
+	writeMetric(w, "proma_collection_failures_total", "counter", "Collection runs for a server which failed.", failures)
+	writeMetric(w, "proma_collection_consecutive_failures", "gauge", "Collection runs for a server which failed since the last success.", consecutive)
+	writeMetric(w, "proma_collection_last_success_timestamp_seconds", "gauge", "Time of the last successful collection from a server.", lastSuccess)
+}
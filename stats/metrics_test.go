@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestWriteMetric(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeMetric(&buf, "proma_test_total", "counter", "Test counter.", []sample{
+		{Labels: []string{"server", "b"}, Value: 2},
+		{Labels: []string{"server", `a"\` + "\n"}, Value: 1.5},
+	})
+
+	expected := `# HELP proma_test_total Test counter.
+# TYPE proma_test_total counter
+proma_test_total{server="a\"\\\n"} 1.5
+proma_test_total{server="b"} 2
+`
+
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expected metric to match (%v / %v)\n", expected, actual)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	c := newTestCollector(t)
+
+	items := []*mastodon.Status{
+		{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+		{URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.insertStatuses("https://mastodon.social", "Outage", items); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.record(ServerResult{Server: "https://mastodon.social", Duration: 1500 * time.Millisecond}, time.Unix(1700000000, 0))
+	c.record(ServerResult{Server: "https://mastodon.social", Duration: time.Second, Err: errors.New("timeout")}, time.Unix(1700000060, 0))
+
+	s := NewServer(context.Background(), c, []string{"outage"}, ServerOptions{})
+
+	rec := httptest.NewRecorder()
+	s.web.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("expected status to match (%v / %v)\n", 200, rec.Code)
+	}
+
+	expected := []string{
+		`proma_posts_inserted_total{server="https://mastodon.social",tag="outage"} 2`,
+		`proma_posts_skipped_total{server="https://mastodon.social",tag="outage"} 2`,
+		`proma_collection_duration_seconds_sum{server="https://mastodon.social"} 2.5`,
+		`proma_collection_duration_seconds_count{server="https://mastodon.social"} 2`,
+		`proma_collection_failures_total{server="https://mastodon.social"} 1`,
+		`proma_collection_consecutive_failures{server="https://mastodon.social"} 1`,
+		`proma_collection_last_success_timestamp_seconds{server="https://mastodon.social"} 1.7e+09`,
+		`# TYPE proma_api_errors_total counter`,
+		`proma_database_size_bytes `,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("expected metrics to contain %q, was: %s\n", line, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package stats
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when a database has been migrated by a newer
+// version of proma than this binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of proma")
+
+// ErrSchemaTooOld is returned when a database opened read-only has not been
+// migrated to the schema this binary reports on.
+var ErrSchemaTooOld = errors.New("database schema is older than this version of proma")
+
+// Migration is a single, ordered upgrade to the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns all migrations of sqlite databases embedded in the
+// binary, in order.
+func Migrations() ([]Migration, error) {
+	return readMigrations("migrations")
+}
+
+// migrationsFor returns the migrations embedded in the binary for the
+// database's driver, in order. PostgreSQL databases are created at the
+// schema of a later sqlite migration, so their versions match.
+func migrationsFor(db *sqlx.DB) ([]Migration, error) {
+	if db.DriverName() == driverPostgres {
+		return readMigrations("migrations/postgres")
+	}
+	return Migrations()
+}
+
+func readMigrations(dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Migration
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		// file names take the form '0001_name.sql'
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		prefix, label, ok := strings.Cut(name, "_")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name: %s", e.Name())
+		}
+
+		version, err := strconv.Atoi(prefix)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", e.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, Migration{Version: version, Name: label, SQL: string(data)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Version < results[j].Version
+	})
+
+	return results, nil
+}
+
+// SchemaVersion returns the version of the most recent migration applied
+// to the database, or zero for an empty database.
+func SchemaVersion(db *sqlx.DB) (int, error) {
+	var version int
+
+	if exists, err := tableExists(db, "schema_version"); err != nil {
+		return 0, err
+	} else if exists {
+		err := db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+		return version, err
+	}
+
+	// databases created before migrations were versioned
+	if exists, err := tableExists(db, "posts"); err != nil || !exists {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// Migrate applies any migrations newer than the database's schema version,
+// each in its own transaction. If dryRun is true, pending migrations are
+// returned without being applied.
+// It returns ErrSchemaTooNew if the database is newer than this binary.
+func Migrate(db *sqlx.DB, dryRun bool) ([]Migration, error) {
+	all, err := migrationsFor(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := SchemaVersion(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if latest := all[len(all)-1].Version; current > latest {
+		return nil, fmt.Errorf("%w (version %d, expected at most %d)", ErrSchemaTooNew, current, latest)
+	}
+
+	var pending []Migration
+
+	for _, m := range all {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		);`); err != nil {
+		return nil, err
+	}
+
+	for _, m := range all {
+		if m.Version != current {
+			continue
+		}
+
+		// record the baseline of a database created before versioning
+		if _, err := db.Exec(db.Rebind(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`),
+			current, m.Name, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, m := range pending {
+		log.Debugf("applying migration %04d_%s", m.Version, m.Name)
+
+		if err := applyMigration(db, m); err != nil {
+			return pending[:i], fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+func applyMigration(db *sqlx.DB, m Migration) error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(tx.Rebind(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func tableExists(db *sqlx.DB, name string) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+
+	if db.DriverName() == driverPostgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	}
+
+	var count int
+	err := db.Get(&count, query, name)
+	return count > 0, err
+}
+
+// Open opens the named database file, or an in-memory database if name
+// is empty, without applying any migrations. A name starting with
+// postgres:// opens a PostgreSQL database.
+func Open(name string) (*sqlx.DB, error) {
+	if isPostgres(name) {
+		log.Debugf("using database: %s\n", redactDSN(name))
+		db, err := sqlx.Open(driverPostgres, name)
+
+		if err != nil {
+			return nil, err
+		}
+		return db, db.Ping()
+	}
+
+	if name == "" {
+		name = ":memory:"
+	}
+
+	log.Debugf("using database: %s\n", name)
+
+	dsn := name
+
+	if name != ":memory:" {
+		// readers, such as the stats server, are not blocked while posts
+		// are written, and writers wait for each other rather than failing
+		dsn = withParam(withParam(name, "_journal_mode", "WAL"), "_busy_timeout", "5000")
+	}
+
+	db, err := sqlx.Open(driverSQLite, dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if name == ":memory:" {
+		// each connection to an in-memory db opens a new, empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, db.Ping()
+}
+
+// withParam returns a database name with an added connection parameter.
+func withParam(name, key, value string) string {
+	sep := "?"
+	if strings.Contains(name, "?") {
+		sep = "&"
+	}
+	return name + sep + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+// OpenReadOnly opens an existing database file without modifying it. Its
+// schema is not upgraded, so it returns ErrSchemaTooOld if the database
+// must first be migrated with 'proma db migrate', and ErrSchemaTooNew if the
+// database is newer than this binary.
+func OpenReadOnly(name string) (*sqlx.DB, error) {
+	var (
+		db  *sqlx.DB
+		err error
+	)
+
+	if isPostgres(name) {
+		log.Debugf("using database (read-only): %s\n", redactDSN(name))
+		db, err = sqlx.Open(driverPostgres, withParam(name, "default_transaction_read_only", "on"))
+	} else {
+		if _, err := os.Stat(name); err != nil {
+			return nil, err
+		}
+
+		log.Debugf("using database (read-only): %s\n", name)
+		db, err = sqlx.Open(driverSQLite, "file:"+name+"?mode=ro")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := SchemaVersion(db)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	all, err := migrationsFor(db)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if latest := all[len(all)-1].Version; current > latest {
+		db.Close()
+		return nil, fmt.Errorf("%w (version %d, expected at most %d)", ErrSchemaTooNew, current, latest)
+	} else if current < latest {
+		db.Close()
+		return nil, fmt.Errorf("%w (version %d, expected %d); run 'proma db migrate' to upgrade it", ErrSchemaTooOld, current, latest)
+	}
+
+	return db, nil
+}
+
+// OpenDB opens the named database as in Open, and upgrades its schema to
+// the latest version.
+func OpenDB(name string) (*sqlx.DB, error) {
+	db, err := Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := Migrate(db, false)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if len(applied) > 0 {
+		log.Debugf("database upgraded to version %d", applied[len(applied)-1].Version)
+	}
+
+	if db.DriverName() == driverPostgres {
+		// content_text is always stored, and indexed by the schema
+		return db, nil
+	}
+
+	if err := fillContentText(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := ensureSearchIndex(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,143 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMigrate(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := all[len(all)-1].Version
+
+	testCases := []struct {
+		name          string
+		setup         string
+		expectApplied int
+		expectErr     error
+	}{
+		{
+			name:          "empty database",
+			expectApplied: len(all),
+		},
+		{
+			name:          "unversioned database",
+			setup:         all[0].SQL,
+			expectApplied: len(all) - 1,
+		},
+		{
+			name: "newer database",
+			setup: `
+				CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL);
+				INSERT INTO schema_version VALUES (9999, 'future', '');`,
+			expectErr: ErrSchemaTooNew,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := openTestDB(t, tc.setup)
+
+			applied, err := Migrate(db, false)
+
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error to match (%v / %v)\n", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(applied) != tc.expectApplied {
+				t.Errorf("expected applied to match (%v / %v)\n", tc.expectApplied, len(applied))
+			}
+
+			version, err := SchemaVersion(db)
+			if err != nil || version != latest {
+				t.Errorf("expected version to match (%v / %v) %v\n", latest, version, err)
+			}
+
+			if pending, _ := Migrate(db, true); len(pending) != 0 {
+				t.Errorf("expected no pending migrations, was %d\n", len(pending))
+			}
+		})
+	}
+}
+
+// openTestDB returns an in-memory database after executing the setup SQL.
+func openTestDB(t *testing.T, setup string) *sqlx.DB {
+	t.Helper()
+
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if setup != "" {
+		db.MustExec(setup)
+	}
+	return db
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		version   int
+		expectErr error
+	}{
+		{name: "current archive", version: all[len(all)-1].Version},
+		{name: "version 2 archive", version: 2, expectErr: ErrSchemaTooOld},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "archive.db")
+			writeTestArchive(t, name, all, tc.version)
+
+			db, err := OpenReadOnly(name)
+
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error to match (%v / %v)\n", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer db.Close()
+
+			if _, err := NewCollector(nil, db).Report(context.Background(), ReportOptions{Tags: []string{"outage"}}); err != nil {
+				t.Errorf("expected report of archive to succeed, was: %v\n", err)
+			}
+		})
+	}
+}
+
+// writeTestArchive creates a database file at a schema version, as written
+// by an earlier version of proma.
+func writeTestArchive(t *testing.T, name string, all []Migration, version int) {
+	t.Helper()
+
+	db, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.MustExec(`CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL);`)
+
+	for _, m := range all {
+		if m.Version > version {
+			break
+		}
+		db.MustExec(m.SQL)
+		db.MustExec(fmt.Sprintf("INSERT INTO schema_version VALUES (%d, '%s', '')", m.Version, m.Name))
+	}
+}
@@ -0,0 +1,32 @@
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_tags_name ON tags (name);
+
+CREATE TABLE posts (
+	id INTEGER PRIMARY KEY,
+	post_id TEXT NOT NULL,
+	account_id TEXT NOT NULL,
+	server TEXT NOT NULL,
+	uri TEXT NOT NULL,
+	lang TEXT DEFAULT 'en' NOT NULL,
+	content_html TEXT,
+	content_text TEXT,
+	created_at TEXT
+);
+
+CREATE UNIQUE INDEX idx_posts_uri ON posts (uri);
+
+CREATE TABLE posts_tags (
+	post_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (post_id, tag_id)
+);
+
+-- index relies on sqlite3 FTS extension (go run .. --tags=fts5)
+-- CREATE VIRTUAL TABLE content_index USING FTS5 (
+--	post_id,
+--	content
+-- );
@@ -0,0 +1,9 @@
+-- tracks the newest post collected for each server and tag.
+-- may already exist in databases created before migrations were versioned.
+CREATE TABLE IF NOT EXISTS timeline_cursors (
+	server TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	last_id TEXT NOT NULL,
+	updated_at TEXT,
+	PRIMARY KEY (server, tag)
+);
@@ -0,0 +1,18 @@
+-- tracks edits and deletions of collected posts, found by rechecking them.
+ALTER TABLE posts ADD COLUMN edited_at TEXT;
+ALTER TABLE posts ADD COLUMN deleted_at TEXT;
+ALTER TABLE posts ADD COLUMN checked_at TEXT;
+
+CREATE INDEX idx_posts_server_checked ON posts (server, checked_at);
+
+-- the content of a post before each edit.
+CREATE TABLE post_revisions (
+	id INTEGER PRIMARY KEY,
+	post_id INTEGER NOT NULL,
+	content_html TEXT,
+	content_text TEXT,
+	edited_at TEXT,
+	replaced_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id);
@@ -0,0 +1,19 @@
+-- authors of collected posts, keyed by their canonical address (user@domain).
+-- posts collected before this migration are not linked to an account.
+CREATE TABLE accounts (
+	acct TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	display_name TEXT NOT NULL DEFAULT '',
+	url TEXT NOT NULL DEFAULT '',
+	bot INTEGER NOT NULL DEFAULT 0,
+	locked INTEGER NOT NULL DEFAULT 0,
+	followers_count INTEGER NOT NULL DEFAULT 0,
+	following_count INTEGER NOT NULL DEFAULT 0,
+	statuses_count INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT,
+	updated_at TEXT
+);
+
+ALTER TABLE posts ADD COLUMN acct TEXT;
+
+CREATE INDEX idx_posts_acct ON posts (acct);
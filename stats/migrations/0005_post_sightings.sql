@@ -0,0 +1,29 @@
+-- records each server a post was collected from, with the ID of the post on
+-- that server. posts are stored once, by URI, however many servers see them.
+CREATE TABLE post_sightings (
+	post_id INTEGER NOT NULL,
+	server TEXT NOT NULL,
+	local_id TEXT NOT NULL,
+	first_seen_at TEXT NOT NULL,
+	PRIMARY KEY (post_id, server)
+);
+
+CREATE INDEX idx_post_sightings_server ON post_sightings (server);
+
+-- the instance a post was published on, taken from the host of its URI.
+ALTER TABLE posts ADD COLUMN origin TEXT;
+
+CREATE INDEX idx_posts_origin ON posts (origin);
+
+-- posts collected earlier were seen only by the server they were collected
+-- from, no earlier than they were created.
+INSERT INTO post_sightings (post_id, server, local_id, first_seen_at)
+SELECT id, server, post_id, COALESCE(created_at, '') FROM posts;
+
+UPDATE posts
+SET origin = lower(substr(
+	substr(uri, instr(uri, '://') + 3),
+	1,
+	instr(substr(uri, instr(uri, '://') + 3) || '/', '/') - 1
+))
+WHERE instr(uri, '://') > 0;
@@ -0,0 +1,44 @@
+-- details of collected posts: replies, content warnings, visibility and
+-- engagement counts as of when the post was collected.
+ALTER TABLE posts ADD COLUMN in_reply_to_id TEXT;
+ALTER TABLE posts ADD COLUMN in_reply_to_account_id TEXT;
+ALTER TABLE posts ADD COLUMN spoiler_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN sensitive INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE posts ADD COLUMN replies_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reblogs_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN favourites_count INTEGER NOT NULL DEFAULT 0;
+
+-- set while a post has only been collected as the target of a boost.
+ALTER TABLE posts ADD COLUMN via_boost INTEGER NOT NULL DEFAULT 0;
+
+-- media attached to a post, in the order shown.
+CREATE TABLE post_media (
+	post_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	url TEXT NOT NULL,
+	preview_url TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (post_id, position)
+);
+
+-- accounts mentioned by a post, by canonical address (user@domain).
+CREATE TABLE post_mentions (
+	post_id INTEGER NOT NULL,
+	acct TEXT NOT NULL,
+	url TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (post_id, acct)
+);
+
+-- accounts which boosted a post, as seen in collected timelines.
+CREATE TABLE boosts (
+	post_id INTEGER NOT NULL,
+	acct TEXT NOT NULL,
+	server TEXT NOT NULL,
+	local_id TEXT NOT NULL,
+	created_at TEXT,
+	PRIMARY KEY (post_id, acct)
+);
+
+CREATE INDEX idx_boosts_acct ON boosts (acct);
@@ -0,0 +1,19 @@
+-- reply, boost and favourite counts of a post each time it was fetched, when
+-- collected and then by each recheck.
+CREATE TABLE post_engagement (
+	post_id INTEGER NOT NULL,
+	captured_at TEXT NOT NULL,
+	replies_count INTEGER NOT NULL,
+	reblogs_count INTEGER NOT NULL,
+	favourites_count INTEGER NOT NULL,
+	PRIMARY KEY (post_id, captured_at)
+);
+
+-- counts stored with posts collected earlier were captured when each post
+-- was first seen.
+INSERT INTO post_engagement (post_id, captured_at, replies_count, reblogs_count, favourites_count)
+SELECT p.id, MIN(ps.first_seen_at), p.replies_count, p.reblogs_count, p.favourites_count
+FROM posts p
+INNER JOIN post_sightings ps ON ps.post_id = p.id
+WHERE p.deleted_at IS NULL
+GROUP BY p.id;
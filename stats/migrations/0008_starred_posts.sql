@@ -0,0 +1,6 @@
+-- posts starred to be kept when the database is pruned.
+ALTER TABLE posts ADD COLUMN starred INTEGER NOT NULL DEFAULT 0;
+
+-- pruning by age, and by the newest posts of each tag.
+CREATE INDEX idx_posts_created_at ON posts (created_at);
+CREATE INDEX idx_posts_tags_tag_id ON posts_tags (tag_id);
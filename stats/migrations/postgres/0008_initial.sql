@@ -0,0 +1,132 @@
+-- the schema of sqlite migrations 0001 to 0008, created at once in new
+-- PostgreSQL databases. later migrations are numbered to match sqlite's.
+CREATE TABLE tags (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_tags_name ON tags (name);
+
+CREATE TABLE accounts (
+	acct TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	display_name TEXT NOT NULL DEFAULT '',
+	url TEXT NOT NULL DEFAULT '',
+	bot BOOLEAN NOT NULL DEFAULT FALSE,
+	locked BOOLEAN NOT NULL DEFAULT FALSE,
+	followers_count BIGINT NOT NULL DEFAULT 0,
+	following_count BIGINT NOT NULL DEFAULT 0,
+	statuses_count BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE posts (
+	id BIGSERIAL PRIMARY KEY,
+	post_id TEXT NOT NULL,
+	account_id TEXT NOT NULL,
+	acct TEXT,
+	server TEXT NOT NULL,
+	uri TEXT NOT NULL,
+	origin TEXT,
+	lang TEXT DEFAULT 'en' NOT NULL,
+	content_html TEXT,
+	content_text TEXT,
+	created_at TIMESTAMPTZ,
+	edited_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	checked_at TIMESTAMPTZ,
+	in_reply_to_id TEXT,
+	in_reply_to_account_id TEXT,
+	spoiler_text TEXT NOT NULL DEFAULT '',
+	sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+	visibility TEXT NOT NULL DEFAULT 'public',
+	replies_count BIGINT NOT NULL DEFAULT 0,
+	reblogs_count BIGINT NOT NULL DEFAULT 0,
+	favourites_count BIGINT NOT NULL DEFAULT 0,
+	via_boost BOOLEAN NOT NULL DEFAULT FALSE,
+	starred BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX idx_posts_uri ON posts (uri);
+CREATE INDEX idx_posts_acct ON posts (acct);
+CREATE INDEX idx_posts_origin ON posts (origin);
+CREATE INDEX idx_posts_created_at ON posts (created_at);
+CREATE INDEX idx_posts_server_checked ON posts (server, checked_at);
+
+-- full-text search, matching the expression searched by postgresDialect.
+CREATE INDEX idx_posts_search ON posts USING GIN (to_tsvector('simple', COALESCE(content_text, '')));
+
+CREATE TABLE posts_tags (
+	post_id BIGINT NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX idx_posts_tags_tag_id ON posts_tags (tag_id);
+
+CREATE TABLE timeline_cursors (
+	server TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	last_id TEXT NOT NULL,
+	updated_at TIMESTAMPTZ,
+	PRIMARY KEY (server, tag)
+);
+
+CREATE TABLE post_revisions (
+	id BIGSERIAL PRIMARY KEY,
+	post_id BIGINT NOT NULL,
+	content_html TEXT,
+	content_text TEXT,
+	edited_at TIMESTAMPTZ,
+	replaced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id);
+
+CREATE TABLE post_sightings (
+	post_id BIGINT NOT NULL,
+	server TEXT NOT NULL,
+	local_id TEXT NOT NULL,
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (post_id, server)
+);
+
+CREATE INDEX idx_post_sightings_server ON post_sightings (server);
+
+CREATE TABLE post_media (
+	post_id BIGINT NOT NULL,
+	position INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	url TEXT NOT NULL,
+	preview_url TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (post_id, position)
+);
+
+CREATE TABLE post_mentions (
+	post_id BIGINT NOT NULL,
+	acct TEXT NOT NULL,
+	url TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (post_id, acct)
+);
+
+CREATE TABLE boosts (
+	post_id BIGINT NOT NULL,
+	acct TEXT NOT NULL,
+	server TEXT NOT NULL,
+	local_id TEXT NOT NULL,
+	created_at TIMESTAMPTZ,
+	PRIMARY KEY (post_id, acct)
+);
+
+CREATE INDEX idx_boosts_acct ON boosts (acct);
+
+CREATE TABLE post_engagement (
+	post_id BIGINT NOT NULL,
+	captured_at TIMESTAMPTZ NOT NULL,
+	replies_count BIGINT NOT NULL,
+	reblogs_count BIGINT NOT NULL,
+	favourites_count BIGINT NOT NULL,
+	PRIMARY KEY (post_id, captured_at)
+);
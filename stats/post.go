@@ -1,34 +1,126 @@
 package stats
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// ErrInvalidCursor is returned when a report cursor can not be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 // Status is a condensed representation of mastodon.Status
 type Status struct {
-	ID        string         `json:"-"`
+	ID        string         `json:"-" db:"id"`
 	URI       string         `json:"uri" db:"uri" `
+	Account   string         `json:"account,omitempty" db:"acct"`
+	Origin    string         `json:"origin,omitempty" db:"origin"`
+	Reach     int            `json:"reach" db:"reach"`
 	Language  string         `json:"lang" db:"lang"`
 	Content   string         `json:"content"`
 	TagList   tagList        `json:"tag_list" db:"tag_list"`
 	CreatedAt sqliteDatetime `json:"created_at" db:"created_at"`
+
+	// EditedAt is when the post was last edited, if found by a recheck.
+	EditedAt *sqliteDatetime `json:"edited_at,omitempty" db:"edited_at"`
+
+	InReplyToID string  `json:"in_reply_to_id,omitempty" db:"in_reply_to_id"`
+	SpoilerText string  `json:"spoiler_text,omitempty" db:"spoiler_text"`
+	Sensitive   bool    `json:"sensitive" db:"sensitive"`
+	Visibility  string  `json:"visibility" db:"visibility"`
+	MediaCount  int     `json:"media_count" db:"media_count"`
+	Mentions    tagList `json:"mentions" db:"mention_list"`
+
+	// Engagement counts, as of when the post was collected or last rechecked.
+	RepliesCount    int64 `json:"replies_count" db:"replies_count"`
+	ReblogsCount    int64 `json:"reblogs_count" db:"reblogs_count"`
+	FavouritesCount int64 `json:"favourites_count" db:"favourites_count"`
+
+	// ViaBoost is set if the post has only been collected as the target
+	// of a boost.
+	ViaBoost bool `json:"via_boost" db:"via_boost"`
+
+	// Starred is set if the post is kept when the database is pruned.
+	Starred bool `json:"starred" db:"starred"`
+}
+
+// Cursor returns an opaque value used to continue a report after this post.
+func (s *Status) Cursor() string {
+	value := time.Time(s.CreatedAt).Format(time.RFC3339Nano) + "|" + s.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodeCursor returns the creation date and ID encoded by Status.Cursor.
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	value, id, ok := strings.Cut(string(data), "|")
+
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, value)
+
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return createdAt, n, nil
 }
 
 // tagList converts a comma-separated list of tag names to a JSON array.
 type tagList string
 
 func (tl tagList) MarshalJSON() ([]byte, error) {
-	parts := strings.Split(string(tl), ",")
-	return json.Marshal(parts)
+	if tl == "" {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(tl.names())
 }
 
-// sqliteDatetime converts a SQLite text date to a time.Time.
+// names returns the tag names in the list.
+func (tl tagList) names() []string {
+	if tl == "" {
+		return nil
+	}
+	return strings.Split(string(tl), ",")
+}
+
+// sqliteDatetime converts a SQLite text date, or a PostgreSQL timestamp, to
+// a time.Time.
 type sqliteDatetime time.Time
 
 func (cv *sqliteDatetime) Scan(src any) error {
-	t, err := time.Parse("2006-01-02 15:04:05-07:00", src.(string))
+	switch v := src.(type) {
+	case time.Time:
+		*cv = sqliteDatetime(v)
+		return nil
+	case []byte:
+		src = string(v)
+	}
+
+	text, ok := src.(string)
+
+	if !ok {
+		return fmt.Errorf("unable to convert %T to a time", src)
+	}
+
+	t, err := time.Parse("2006-01-02 15:04:05-07:00", text)
 
 	if err != nil {
 		return err
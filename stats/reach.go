@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// TagReach summarizes how widely the posts with a tag have federated across
+// the servers being collected from.
+type TagReach struct {
+	Tag   string `json:"tag" db:"tag"`
+	Posts int    `json:"posts" db:"posts"`
+
+	// Origins is the number of instances the posts were published on.
+	Origins int `json:"origins" db:"origins"`
+
+	// Servers is the number of servers any of the posts were seen on.
+	Servers int `json:"servers" db:"servers"`
+
+	// AverageReach is the mean number of servers each post was seen on.
+	AverageReach float64 `json:"average_reach" db:"average_reach"`
+}
+
+// originHost returns the instance a post was published on, from the host
+// of its URI, or an empty string if the URI has no host.
+func originHost(uri string) string {
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// recordSighting records that a post was seen on a server with a local ID,
+// if it has not been seen there before.
+func (w *writeTx) recordSighting(postID int64, server string, localID mastodon.ID) error {
+	_, err := w.exec(`
+		INSERT INTO post_sightings (post_id, server, local_id, first_seen_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING;`,
+		postID, server, localID, time.Now().UTC(),
+	)
+	return err
+}
+
+// TagReach returns the reach of the posts with each tag, ordered by tag.
+func (s *sqlStore) TagReach(ctx context.Context, opts ReportOptions) ([]TagReach, error) {
+	var results []TagReach
+
+	where, args := TrendOptions{ReportOptions: opts}.tagWhere()
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			t.name tag,
+			COUNT(DISTINCT p.id) posts,
+			COUNT(DISTINCT p.origin) origins,
+			COUNT(DISTINCT ps.server) servers,
+			CAST(COUNT(*) AS REAL) / COUNT(DISTINCT p.id) average_reach
+		FROM
+			posts p
+		INNER JOIN
+			post_sightings ps ON ps.post_id = p.id
+		INNER JOIN
+			posts_tags pt ON pt.post_id = p.id
+		INNER JOIN
+			tags t ON t.id = pt.tag_id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY tag
+		ORDER BY tag;
+	`, args...)
+
+	return results, err
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestTagReach(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC()
+
+	shared := &mastodon.Status{ID: "100", URI: "https://Origin.example/users/a/statuses/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)}
+
+	insertTestStatuses(t, c, "https://mastodon.social", shared,
+		&mastodon.Status{ID: "101", URI: "https://mastodon.social/users/b/statuses/2", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: now.Add(-time.Hour)},
+	)
+
+	// the same post, with a different local ID on each server
+	for i, server := range []string{"https://hachyderm.io", "https://indieweb.social", "https://hachyderm.io"} {
+		seen := *shared
+		seen.ID = mastodon.ID(fmt.Sprint(200 + i))
+		insertTestStatuses(t, c, server, &seen)
+	}
+
+	reach, err := c.TagReach(context.Background(), ReportOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TagReach{
+		{Tag: "outage", Posts: 2, Origins: 2, Servers: 3, AverageReach: 2},
+		{Tag: "power", Posts: 1, Origins: 1, Servers: 1, AverageReach: 1},
+	}
+
+	if fmt.Sprint(reach) != fmt.Sprint(expected) {
+		t.Errorf("expected reach to match (%v / %v)\n", expected, reach)
+	}
+
+	results, err := c.Report(context.Background(), ReportOptions{Servers: []string{"indieweb.social"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Origin != "origin.example" || results[0].Reach != 3 {
+		t.Errorf("expected post seen on server, was: %+v\n", results)
+	}
+
+	counts, err := c.ServerCounts(context.Background(), TrendOptions{ReportOptions: ReportOptions{Tags: []string{"outage"}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(counts) != 3 || counts[0].Server != "https://hachyderm.io" || counts[0].Count != 1 {
+		t.Errorf("expected post counted for each server, was: %+v\n", counts)
+	}
+}
+
+func TestMigrateSightings(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setup := "CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL);"
+
+	for _, m := range all {
+		if m.Name == "post_sightings" {
+			break
+		}
+		setup += m.SQL + fmt.Sprintf("INSERT INTO schema_version VALUES (%d, '%s', '');", m.Version, m.Name)
+	}
+
+	db := openTestDB(t, setup+`
+		INSERT INTO posts (post_id, account_id, server, uri, created_at)
+		VALUES ('7', '1', 'https://mastodon.social', 'https://Hachyderm.io/users/a/statuses/7', '2023-07-01 12:00:00+00:00');`)
+
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var sighting struct {
+		Server  string `db:"server"`
+		LocalID string `db:"local_id"`
+		Origin  string `db:"origin"`
+	}
+
+	err = db.Get(&sighting, `
+		SELECT ps.server, ps.local_id, p.origin
+		FROM posts p JOIN post_sightings ps ON ps.post_id = p.id`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sighting.Server != "https://mastodon.social" || sighting.LocalID != "7" || sighting.Origin != "hachyderm.io" {
+		t.Errorf("expected existing post to be sighted, was: %+v\n", sighting)
+	}
+}
@@ -0,0 +1,264 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sort orders for reports.
+const (
+	SortNewest     = "newest"
+	SortOldest     = "oldest"
+	SortEngagement = "engagement"
+)
+
+// engagement is the total of a post's replies, boosts and favourites.
+const engagement = "(p.replies_count + p.reblogs_count + p.favourites_count)"
+
+// ReportOptions select the posts included in a report. Zero values
+// do not filter results.
+type ReportOptions struct {
+	// Tags match posts with any of the tags, or all of them if AllTags is set.
+	Tags    []string
+	AllTags bool
+
+	// From and To limit posts to those created in the interval [From, To).
+	From time.Time
+	To   time.Time
+
+	Langs   []string
+	Servers []string
+
+	// Accounts match posts by account address (user@domain), or by
+	// account ID on the server a post was collected from.
+	Accounts []string
+
+	// ExcludeBoosts omits posts only collected as the target of a boost,
+	// ExcludeReplies omits replies, and ExcludeSensitive omits posts with
+	// a content warning or sensitive media.
+	ExcludeBoosts    bool
+	ExcludeReplies   bool
+	ExcludeSensitive bool
+
+	Limit  int
+	Offset int
+	Sort   string
+
+	// Cursor continues a report after the last post of a previous
+	// page, as returned by Status.Cursor.
+	Cursor string
+}
+
+// where returns the conditions and arguments for the options, for use
+// in a query where posts are aliased as 'p'.
+func (o ReportOptions) where() ([]string, []any) {
+	var (
+		// posts deleted by their authors are kept only to avoid collecting them again
+		where = []string{"p.deleted_at IS NULL"}
+		args  []any
+	)
+
+	if len(o.Tags) > 0 {
+		tags := lowerAll(o.Tags)
+
+		if o.AllTags {
+			where = append(where, `(
+				SELECT COUNT(DISTINCT t.name) FROM posts_tags pt
+				JOIN tags t ON t.id = pt.tag_id
+				WHERE pt.post_id = p.id AND t.name IN (?)
+			) = ?`)
+			args = append(args, tags, len(uniqueStrings(tags)))
+		} else {
+			where = append(where, `EXISTS (
+				SELECT 1 FROM posts_tags pt
+				JOIN tags t ON t.id = pt.tag_id
+				WHERE pt.post_id = p.id AND t.name IN (?)
+			)`)
+			args = append(args, tags)
+		}
+	}
+
+	if !o.From.IsZero() {
+		where = append(where, "p.created_at >= ?")
+		args = append(args, o.From.UTC())
+	}
+
+	if !o.To.IsZero() {
+		where = append(where, "p.created_at < ?")
+		args = append(args, o.To.UTC())
+	}
+
+	if len(o.Langs) > 0 {
+		where = append(where, "p.lang IN (?)")
+		args = append(args, o.Langs)
+	}
+
+	if len(o.Servers) > 0 {
+		servers := make([]string, len(o.Servers))
+		for i, s := range o.Servers {
+			servers[i] = serverURL(s)
+		}
+		where = append(where, `EXISTS (
+			SELECT 1 FROM post_sightings ps
+			WHERE ps.post_id = p.id AND ps.server IN (?)
+		)`)
+		args = append(args, servers)
+	}
+
+	if len(o.Accounts) > 0 {
+		where = append(where, "(p.account_id IN (?) OR p.acct IN (?))")
+		args = append(args, o.Accounts, lowerAll(o.Accounts))
+	}
+
+	if o.ExcludeBoosts {
+		where = append(where, "NOT p.via_boost")
+	}
+
+	if o.ExcludeReplies {
+		where = append(where, "p.in_reply_to_id IS NULL")
+	}
+
+	if o.ExcludeSensitive {
+		where = append(where, "p.spoiler_text = '' AND NOT p.sensitive")
+	}
+
+	return where, args
+}
+
+// cursorWhere returns the condition selecting posts after the cursor
+// in the sort order of the options.
+func (o ReportOptions) cursorWhere() (string, []any, error) {
+	if o.Sort == SortEngagement {
+		return "", nil, fmt.Errorf("%w (cursors are not supported when sorting by %s)", ErrInvalidCursor, SortEngagement)
+	}
+
+	createdAt, id, err := decodeCursor(o.Cursor)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	op := "<"
+	if o.Sort == SortOldest {
+		op = ">"
+	}
+
+	return "(p.created_at " + op + " ? OR (p.created_at = ? AND p.id " + op + " ?))",
+		[]any{createdAt.UTC(), createdAt.UTC(), id}, nil
+}
+
+// orderBy returns the ORDER BY expression for the sort option.
+func (o ReportOptions) orderBy() (string, error) {
+	switch o.Sort {
+	case "", SortNewest:
+		return "p.created_at DESC, p.id DESC", nil
+	case SortOldest:
+		return "p.created_at ASC, p.id ASC", nil
+	case SortEngagement:
+		return engagement + " DESC, p.created_at DESC, p.id DESC", nil
+	default:
+		return "", fmt.Errorf("unknown sort order: %s (expected '%s', '%s' or '%s')", o.Sort, SortNewest, SortOldest, SortEngagement)
+	}
+}
+
+// Report generates a list of posts matching the provided options.
+// It returns an error if the underlying SQL query fails.
+func (s *sqlStore) Report(ctx context.Context, opts ReportOptions) ([]*Status, error) {
+	var results []*Status
+
+	orderBy, err := opts.orderBy()
+
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := opts.where()
+
+	if opts.Cursor != "" {
+		cond, cursorArgs, err := opts.cursorWhere()
+
+		if err != nil {
+			return nil, err
+		}
+
+		where = append(where, cond)
+		args = append(args, cursorArgs...)
+	}
+
+	limit := s.dialect.noLimit()
+	if opts.Limit > 0 {
+		limit = strconv.Itoa(opts.Limit)
+	}
+
+	args = append(args, opts.Offset)
+
+	err = s.selectContext(ctx, &results, `
+		SELECT
+		p.id, created_at, edited_at, uri, COALESCE(acct, '') acct, COALESCE(origin, '') origin, lang, content_html as content,
+		(SELECT COUNT(*) FROM post_sightings ps WHERE ps.post_id = p.id) reach,
+		COALESCE(in_reply_to_id, '') in_reply_to_id, spoiler_text, sensitive, visibility,
+		replies_count, reblogs_count, favourites_count, via_boost, starred,
+		(SELECT COUNT(*) FROM post_media pm WHERE pm.post_id = p.id) media_count,
+		`+s.dialect.list("SELECT pmn.acct AS item FROM post_mentions pmn WHERE pmn.post_id = p.id ORDER BY pmn.acct")+` mention_list,
+		`+s.dialect.list(tagListQuery)+` tag_list
+		FROM
+			posts p
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		ORDER BY `+orderBy+`
+		LIMIT `+limit+` OFFSET ?;
+	`, args...)
+
+	return results, err
+}
+
+// ParseSince returns the time described by value, which is either a date,
+// an RFC3339 timestamp, or a duration (in hours, minutes or days) before now.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid number of days: %s", value)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+
+	d, err := time.ParseDuration(value)
+
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("expected a date or duration, was: %s", value)
+	}
+
+	return now.Add(-d), nil
+}
+
+// serverURL returns the URL stored for a server, accepting either a server
+// name (mastodon.social) or a URL (https://mastodon.social).
+func serverURL(server string) string {
+	if strings.Contains(server, "://") {
+		return strings.TrimSuffix(server, "/")
+	}
+	return "https://" + server
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var results []string
+
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			results = append(results, v)
+		}
+	}
+	return results
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestReport(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC()
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Account: mastodon.Account{ID: "1"}, Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: now.Add(-time.Hour)},
+		&mastodon.Status{URI: "https://a.example/2", Account: mastodon.Account{ID: "2"}, Tags: []mastodon.Tag{{Name: "outage"}}, Language: "de", CreatedAt: now.Add(-2 * time.Hour)},
+		&mastodon.Status{URI: "https://a.example/3", Account: mastodon.Account{ID: "1"}, Tags: []mastodon.Tag{{Name: "power"}}, CreatedAt: now.Add(-72 * time.Hour)},
+	)
+	insertTestStatuses(t, c, "https://indieweb.social",
+		&mastodon.Status{URI: "https://a.example/4", Account: mastodon.Account{ID: "3"}, Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-3 * time.Hour)},
+	)
+
+	testCases := []struct {
+		name      string
+		input     ReportOptions
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "any tag",
+			input:    ReportOptions{Tags: []string{"outage", "power"}},
+			expected: []string{"1", "2", "4", "3"},
+		},
+		{
+			name:     "all tags",
+			input:    ReportOptions{Tags: []string{"outage", "Power"}, AllTags: true},
+			expected: []string{"1"},
+		},
+		{
+			name:     "time range",
+			input:    ReportOptions{From: now.Add(-150 * time.Minute), To: now.Add(-30 * time.Minute)},
+			expected: []string{"1", "2"},
+		},
+		{
+			name:     "server, lang and account",
+			input:    ReportOptions{Servers: []string{"mastodon.social"}, Langs: []string{"en"}, Accounts: []string{"1"}},
+			expected: []string{"1", "3"},
+		},
+		{
+			name:     "oldest with limit and offset",
+			input:    ReportOptions{Sort: SortOldest, Limit: 2, Offset: 1},
+			expected: []string{"4", "2"},
+		},
+		{
+			name:      "invalid sort",
+			input:     ReportOptions{Sort: "random"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := c.Report(context.Background(), tc.input)
+
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %v, was: %v\n", tc.expectErr, err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, r.URI[len("https://a.example/"):])
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expected) && !tc.expectErr {
+				t.Errorf("expected posts to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// newTestCollector returns a collector without clients, using a migrated
+// in-memory database, or a new schema of the PostgreSQL database named by
+// PROMA_TEST_POSTGRES if set.
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+
+	db := openTestDB(t, "")
+	if dsn := os.Getenv("PROMA_TEST_POSTGRES"); dsn != "" {
+		db = openPostgresTestDB(t, dsn)
+	}
+
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatal(err)
+	}
+	return NewCollector(nil, db)
+}
+
+func insertTestStatuses(t *testing.T, c *Collector, server string, items ...*mastodon.Status) {
+	t.Helper()
+
+	if _, err := c.insertStatuses(server, "", items); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,316 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrPostNotFound is returned when a post is not stored in the database.
+var ErrPostNotFound = errors.New("post not found")
+
+// pruneBatch limits the number of posts removed in a single transaction, so
+// that collection is not blocked for long while pruning.
+const pruneBatch = 500
+
+// postTables are the tables holding details of a post, by post_id.
+var postTables = []string{
+	"post_revisions",
+	"post_media",
+	"post_mentions",
+	"posts_tags",
+	"post_sightings",
+	"boosts",
+	"post_engagement",
+}
+
+// Retention limits the posts kept in the database. Posts older than MaxAge,
+// and posts beyond the newest MaxPostsPerTag of every tag they have, are
+// pruned. A zero limit is not applied.
+type Retention struct {
+	MaxAge         time.Duration
+	MaxPostsPerTag int
+
+	// KeepStarred keeps starred posts, whatever their age.
+	KeepStarred bool
+}
+
+// Enabled reports whether any limit is set.
+func (r Retention) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxPostsPerTag > 0
+}
+
+func (r Retention) String() string {
+	var limits []string
+
+	if r.MaxAge > 0 {
+		age := r.MaxAge.String()
+		if r.MaxAge%(24*time.Hour) == 0 {
+			age = fmt.Sprintf("%dd", r.MaxAge/(24*time.Hour))
+		}
+		limits = append(limits, "posts from the last "+age)
+	}
+
+	if r.MaxPostsPerTag > 0 {
+		limits = append(limits, fmt.Sprintf("up to %d posts per tag", r.MaxPostsPerTag))
+	}
+
+	if len(limits) == 0 {
+		return "all posts"
+	}
+
+	if r.KeepStarred {
+		limits = append(limits, "and starred posts")
+	}
+
+	return strings.Join(limits, ", ")
+}
+
+// ParseAge parses a number of days (e.g. 90d) or a duration (e.g. 36h).
+func ParseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("expected a duration, was: %s", value)
+	}
+
+	return d, nil
+}
+
+// SetRetention sets when posts beyond the retention limits are pruned, and
+// the database analyzed, while the collector is started. A nil schedule
+// disables pruning.
+func (c *Collector) SetRetention(r Retention, s Schedule) {
+	c.retention = r
+	c.prune = s
+}
+
+// SetVacuum sets when the database is vacuumed to reclaim the space of
+// pruned posts, while the collector is started. A nil schedule disables
+// vacuuming.
+func (c *Collector) SetVacuum(s Schedule) {
+	c.vacuum = s
+}
+
+// Prune removes posts beyond the retention limits, along with their tags,
+// sightings, revisions and other details. It returns the number of posts
+// removed, or that would be removed if dryRun is set.
+//
+// Pruned posts older than the newest post collected for a tag are not
+// collected again, unless backfilled.
+func (s *sqlStore) Prune(ctx context.Context, r Retention, dryRun bool) (int, error) {
+	if !r.Enabled() {
+		return 0, nil
+	}
+
+	ids, err := s.prunable(ctx, r)
+
+	if err != nil || dryRun {
+		return len(ids), err
+	}
+
+	for start := 0; start < len(ids); start += pruneBatch {
+		end := start + pruneBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return start, err
+		}
+
+		err := s.write(func(w *writeTx) error {
+			return w.deletePosts(ids[start:end])
+		})
+
+		if err != nil {
+			return start, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// prunable returns the IDs of posts beyond the retention limits.
+func (s *sqlStore) prunable(ctx context.Context, r Retention) ([]int64, error) {
+	var (
+		limits []string
+		args   []any
+	)
+
+	if r.MaxAge > 0 {
+		limits = append(limits, "p.created_at < ?")
+		args = append(args, time.Now().Add(-r.MaxAge).UTC())
+	}
+
+	if r.MaxPostsPerTag > 0 {
+		// posts are kept if among the newest posts of any of their tags
+		limits = append(limits, `(
+			EXISTS (SELECT 1 FROM posts_tags WHERE post_id = p.id)
+			AND p.id NOT IN (
+				SELECT post_id FROM (
+					SELECT
+						pt.post_id,
+						ROW_NUMBER() OVER (PARTITION BY pt.tag_id ORDER BY pp.created_at DESC, pp.id DESC) AS position
+					FROM posts_tags pt
+					INNER JOIN posts pp ON pp.id = pt.post_id
+				) ranked
+				WHERE position <= ?
+			)
+		)`)
+		args = append(args, r.MaxPostsPerTag)
+	}
+
+	where := "(" + strings.Join(limits, " OR ") + ")"
+
+	if r.KeepStarred {
+		where += " AND NOT p.starred"
+	}
+
+	var ids []int64
+
+	err := s.selectContext(ctx, &ids, "SELECT p.id FROM posts p WHERE "+where+" ORDER BY p.id", args...)
+
+	return ids, err
+}
+
+// deletePosts removes posts and their details.
+func (w *writeTx) deletePosts(ids []int64) error {
+	deleteFrom := func(table, column string) error {
+		query, args, err := sqlx.In("DELETE FROM "+table+" WHERE "+column+" IN (?)", ids)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = w.tx.Exec(w.tx.Rebind(query), args...)
+		return err
+	}
+
+	for _, table := range postTables {
+		if err := deleteFrom(table, "post_id"); err != nil {
+			return err
+		}
+	}
+
+	return deleteFrom("posts", "id")
+}
+
+// Star sets whether the post with a URI is kept when the database is pruned.
+func (s *sqlStore) Star(ctx context.Context, uri string, starred bool) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	res, err := s.execContext(ctx, "UPDATE posts SET starred = ? WHERE uri = ?", starred, uri)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: %s", ErrPostNotFound, uri)
+	}
+
+	return nil
+}
+
+// Optimize updates the statistics used by the query planner and, if vacuum
+// is set, rebuilds the database to reclaim unused space.
+func (s *sqlStore) Optimize(ctx context.Context, vacuum bool) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	if _, err := s.execContext(ctx, "ANALYZE"); err != nil {
+		return err
+	}
+
+	if !vacuum {
+		return nil
+	}
+
+	started := time.Now()
+
+	if _, err := s.execContext(ctx, "VACUUM"); err != nil {
+		return err
+	}
+
+	log.Infof("vacuumed database in %s", time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// startMaintenance prunes and vacuums the database in the background on the
+// collector's schedules, if set, until the context is canceled.
+func (c *Collector) startMaintenance(ctx context.Context, wg *sync.WaitGroup) {
+	if c.prune != nil && c.retention.Enabled() {
+		log.Infof("keeping %s, pruning %s", c.retention, c.prune)
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			runScheduled(ctx, c.prune, func() {
+				n, err := c.Prune(ctx, c.retention, false)
+
+				if err != nil {
+					log.Errorf("prune failed: %v", err)
+					return
+				}
+				log.Infof("pruned %d posts, keeping %s", n, c.retention)
+
+				if err := c.Optimize(ctx, false); err != nil {
+					log.Errorf("analyze failed: %v", err)
+				}
+			})
+		}()
+	}
+
+	if c.vacuum != nil {
+		log.Infof("vacuuming database %s", c.vacuum)
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			runScheduled(ctx, c.vacuum, func() {
+				if err := c.Optimize(ctx, true); err != nil {
+					log.Errorf("vacuum failed: %v", err)
+				}
+			})
+		}()
+	}
+}
+
+// runScheduled calls fn at each time of the schedule, until the context is
+// canceled.
+func runScheduled(ctx context.Context, s Schedule, fn func()) {
+	for {
+		timer := time.NewTimer(time.Until(s.Next(time.Now())))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		fn()
+	}
+}
@@ -0,0 +1,139 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestParseAge(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{value: "90d", expected: 90 * 24 * time.Hour},
+		{value: "36h", expected: 36 * time.Hour},
+		{value: "-1d", expectErr: true},
+		{value: "2023-07-01", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := ParseAge(tc.value)
+
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %v, was: %v\n", tc.expectErr, err)
+			}
+
+			if actual != tc.expected {
+				t.Errorf("expected age to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testCases := []struct {
+		name      string
+		retention Retention
+		dryRun    bool
+		expected  []string
+	}{
+		{
+			name:      "no limits",
+			retention: Retention{},
+			expected:  []string{"5", "4", "3", "2", "1"},
+		},
+		{
+			name:      "max age",
+			retention: Retention{MaxAge: 36 * time.Hour},
+			expected:  []string{"5", "4", "3"},
+		},
+		{
+			name:      "max age, keeping starred",
+			retention: Retention{MaxAge: 36 * time.Hour, KeepStarred: true},
+			expected:  []string{"5", "4", "3", "1"},
+		},
+		{
+			// 3 is kept as one of the newest posts tagged 'flood'
+			name:      "max posts per tag",
+			retention: Retention{MaxPostsPerTag: 2},
+			expected:  []string{"5", "4", "3"},
+		},
+		{
+			name:      "dry run",
+			retention: Retention{MaxAge: time.Hour},
+			dryRun:    true,
+			expected:  []string{"5", "4", "3", "2", "1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCollector(t)
+			now := time.Now().UTC()
+			outage := []mastodon.Tag{{Name: "outage"}}
+
+			insertTestStatuses(t, c, "https://mastodon.social",
+				&mastodon.Status{ID: "1", URI: "https://a.example/1", Tags: outage, CreatedAt: now.Add(-72 * time.Hour)},
+				&mastodon.Status{ID: "2", URI: "https://a.example/2", Tags: outage, CreatedAt: now.Add(-48 * time.Hour)},
+				&mastodon.Status{ID: "3", URI: "https://a.example/3", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "flood"}}, CreatedAt: now.Add(-24 * time.Hour)},
+				&mastodon.Status{ID: "4", URI: "https://a.example/4", Tags: outage, CreatedAt: now.Add(-2 * time.Hour)},
+				&mastodon.Status{ID: "5", URI: "https://a.example/5", Tags: outage, CreatedAt: now.Add(-time.Hour)},
+			)
+
+			if err := c.Star(context.Background(), "https://a.example/1", true); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := c.Prune(context.Background(), tc.retention, tc.dryRun)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := c.Report(context.Background(), ReportOptions{})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, r.URI[len("https://a.example/"):])
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected posts to match (%v / %v)\n", tc.expected, actual)
+			}
+
+			if removed := 5 - len(tc.expected); !tc.dryRun && n != removed {
+				t.Errorf("expected pruned posts to match (%v / %v)\n", removed, n)
+			}
+
+			var orphans int
+			if err := testStore(c).get(&orphans, "SELECT COUNT(*) FROM post_sightings WHERE post_id NOT IN (SELECT id FROM posts)"); err != nil {
+				t.Fatal(err)
+			}
+
+			if orphans != 0 {
+				t.Errorf("expected details of pruned posts to be removed, found %d sightings\n", orphans)
+			}
+
+			if err := c.Optimize(context.Background(), true); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestStarMissingPost(t *testing.T) {
+	c := newTestCollector(t)
+
+	if err := c.Star(context.Background(), "https://a.example/1", true); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound, was: %v\n", err)
+	}
+}
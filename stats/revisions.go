@@ -0,0 +1,315 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// recheckBatch limits the number of posts rechecked on a server in a single
+// run. Posts checked least recently are rechecked first.
+const recheckBatch = 100
+
+// Revision is the content of a post before it was edited.
+type Revision struct {
+	Content string `json:"content" db:"content"`
+
+	// EditedAt is when this content was written, or nil for the original post.
+	EditedAt *sqliteDatetime `json:"edited_at,omitempty" db:"edited_at"`
+
+	// ReplacedAt is when this content was replaced by an edit.
+	ReplacedAt sqliteDatetime `json:"replaced_at" db:"replaced_at"`
+}
+
+// SetPurgeDeleted sets whether posts found deleted when rechecked are
+// removed from the database. Otherwise, their content and revisions are
+// removed, and the post is marked deleted.
+func (c *Collector) SetPurgeDeleted(purge bool) {
+	c.purgeDeleted = purge
+}
+
+// SetRecheck sets when posts created within the window are rechecked for
+// edits and deletions, while the collector is started. A nil schedule
+// disables rechecks.
+func (c *Collector) SetRecheck(s Schedule, window time.Duration) {
+	c.recheck = s
+	c.recheckWindow = window
+}
+
+// Recheck fetches posts created within the window from the server they were
+// collected from, recording a snapshot of their engagement counts, storing
+// the prior content of edited posts as revisions, and marking or purging
+// posts which have been deleted.
+//
+// Servers are rechecked concurrently. It returns a summary of the run, where
+// the posts of each server are those found edited or deleted, and an error
+// only if rechecks failed for every server.
+func (c *Collector) Recheck(ctx context.Context, window time.Duration) (*RunSummary, error) {
+	return c.recheckServers(ctx, c.clients, window)
+}
+
+// recheckServers rechecks posts collected from each of the clients. Like
+// backfill, a batch may wait out rate limits, so it is not bounded by the
+// server timeout.
+func (c *Collector) recheckServers(ctx context.Context, clients []*mastodon.Client, window time.Duration) (*RunSummary, error) {
+	cutoff := time.Now().Add(-window)
+
+	summary := c.eachServer(ctx, clients, 0, func(ctx context.Context, cl *mastodon.Client) (int, error) {
+		log.Info("rechecking posts from server: ", cl.Config.Server)
+		return c.recheckServer(ctx, cl, cutoff)
+	})
+
+	log.Info(summary)
+	return summary, summary.Err()
+}
+
+// recheckServer fetches a batch of posts created since the cutoff from a
+// server. It returns the number of posts found edited or deleted.
+func (c *Collector) recheckServer(ctx context.Context, cl *mastodon.Client, cutoff time.Time) (int, error) {
+	server := cl.Config.Server
+
+	posts, err := c.PostsToRecheck(ctx, server, cutoff, recheckBatch)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var changed int
+
+	for _, p := range posts {
+		if rl, ok := client.Budget(cl); ok && rl.Low(time.Now(), budgetReserve) {
+			log.Infof("pausing rechecks on %s, %d of %d requests remain until %s",
+				server, rl.Remaining, rl.Limit, rl.Reset.Format(time.Kitchen))
+			return changed, nil
+		}
+
+		status, err := client.GetStatus(ctx, cl, p.PostID)
+
+		switch {
+		case errors.Is(err, client.ErrStatusNotFound):
+			log.Debugf("post %s deleted from %s", p.PostID, server)
+
+			if err := c.RemovePost(p.ID, c.purgeDeleted); err != nil {
+				return changed, err
+			}
+			changed++
+			continue
+		case err != nil:
+			return changed, err
+		}
+
+		edited, err := c.UpdatePost(p, status)
+
+		if err != nil {
+			return changed, err
+		}
+
+		if edited {
+			log.Debugf("post %s edited on %s", p.PostID, server)
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+// PostsToRecheck returns up to limit posts collected from a server and
+// created since the cutoff, those checked least recently first.
+func (s *sqlStore) PostsToRecheck(ctx context.Context, server string, cutoff time.Time, limit int) ([]StoredPost, error) {
+	var posts []StoredPost
+
+	err := s.selectContext(ctx, &posts, `
+		SELECT id, post_id, content_html, edited_at
+		FROM posts
+		WHERE server = ? AND deleted_at IS NULL AND created_at >= ?
+		ORDER BY checked_at IS NOT NULL, checked_at, id
+		LIMIT ?;`,
+		server, cutoff.UTC(), limit,
+	)
+
+	return posts, err
+}
+
+// UpdatePost stores the engagement counts, edit time and content of a
+// rechecked post, keeping its prior content as a revision if it has changed.
+// It reports whether the content changed.
+func (s *sqlStore) UpdatePost(p StoredPost, status *client.Status) (bool, error) {
+	var changed bool
+
+	err := s.write(func(w *writeTx) error {
+		now := time.Now().UTC()
+
+		if _, err := w.exec(`
+			UPDATE posts
+			SET replies_count = ?, reblogs_count = ?, favourites_count = ?, checked_at = ?
+			WHERE id = ?;`,
+			status.RepliesCount, status.ReblogsCount, status.FavouritesCount, now, p.ID,
+		); err != nil {
+			return err
+		}
+
+		if err := w.recordEngagement(p.ID, &status.Status); err != nil {
+			return err
+		}
+
+		if status.EditedAt == nil || p.EditedAt != nil && time.Time(*p.EditedAt).Equal(*status.EditedAt) {
+			return nil
+		}
+
+		editedAt := status.EditedAt.UTC()
+		changed = p.Content.String != status.Content
+
+		if changed {
+			var previous any
+			if p.EditedAt != nil {
+				previous = time.Time(*p.EditedAt).UTC()
+			}
+
+			if _, err := w.exec(`
+				INSERT INTO post_revisions (post_id, content_html, content_text, edited_at, replaced_at)
+				VALUES (
+					?,
+					(SELECT content_html FROM posts WHERE id = ?),
+					(SELECT content_text FROM posts WHERE id = ?),
+					?, ?
+				);`,
+				p.ID, p.ID, p.ID, previous, editedAt,
+			); err != nil {
+				return err
+			}
+
+			if _, err := w.exec(`UPDATE posts SET content_html = ?, content_text = ?, lang = ? WHERE id = ?`,
+				status.Content, plainText(status.Content), coalesceString("en", status.Language), p.ID,
+			); err != nil {
+				return err
+			}
+
+			if _, err := w.exec("DELETE FROM posts_tags WHERE post_id = ?", p.ID); err != nil {
+				return err
+			}
+
+			if err := w.addTags(p.ID, status.Tags); err != nil {
+				return err
+			}
+		}
+
+		_, err := w.exec("UPDATE posts SET edited_at = ?, checked_at = ? WHERE id = ?", editedAt, now, p.ID)
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// addTags adds tags to a stored post, storing any tags not seen before.
+func (w *writeTx) addTags(postID int64, tags []mastodon.Tag) error {
+	for _, tag := range tags {
+		tagID, err := w.tagID(tag.Name)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.exec(`
+			INSERT INTO posts_tags (post_id, tag_id) VALUES (?, ?)
+			ON CONFLICT DO NOTHING;`, postID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagID returns the ID of a tag, storing it if not seen before.
+func (w *writeTx) tagID(name string) (int64, error) {
+	if id, ok := w.tagIDs[name]; ok {
+		return id, nil
+	}
+
+	if _, err := w.exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING;`, name); err != nil {
+		return 0, err
+	}
+
+	var id int64
+
+	if err := w.get(&id, "SELECT id FROM tags WHERE name = ?", name); err != nil {
+		return 0, err
+	}
+
+	w.tagIDs[name] = id
+	return id, nil
+}
+
+// RemovePost removes a post deleted by its author. The post is purged if
+// purge is set, and is otherwise kept without its content, media or
+// mentions so that it is not collected again.
+func (s *sqlStore) RemovePost(postID int64, purge bool) error {
+	return s.write(func(w *writeTx) error {
+		if purge {
+			return w.deletePosts([]int64{postID})
+		}
+
+		for _, table := range []string{"post_revisions", "post_media", "post_mentions"} {
+			if _, err := w.exec("DELETE FROM "+table+" WHERE post_id = ?", postID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+
+		_, err := w.exec(`
+			UPDATE posts
+			SET content_html = NULL, content_text = NULL, deleted_at = ?, checked_at = ?
+			WHERE id = ?;`,
+			now, now, postID,
+		)
+		return err
+	})
+}
+
+// Revisions returns the prior content of a post, oldest first.
+func (s *sqlStore) Revisions(ctx context.Context, uri string) ([]*Revision, error) {
+	var results []*Revision
+
+	err := s.selectContext(ctx, &results, `
+		SELECT r.content_html content, r.edited_at, r.replaced_at
+		FROM post_revisions r
+		INNER JOIN posts p ON p.id = r.post_id
+		WHERE p.uri = ?
+		ORDER BY r.replaced_at, r.id;`, uri)
+
+	return results, err
+}
+
+// startRecheck runs rechecks of posts collected from the clients in the
+// background if a recheck schedule is set, until the context is canceled.
+func (c *Collector) startRecheck(ctx context.Context, wg *sync.WaitGroup, clients []*mastodon.Client) {
+	if c.recheck == nil {
+		return
+	}
+
+	log.Infof("rechecking posts from the last %s %s", c.recheckWindow, c.recheck)
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		c.runRecheck(ctx, clients)
+	}()
+}
+
+// runRecheck rechecks posts collected from the clients on the collector's
+// recheck schedule, until the context is canceled.
+func (c *Collector) runRecheck(ctx context.Context, clients []*mastodon.Client) {
+	runScheduled(ctx, c.recheck, func() {
+		if _, err := c.recheckServers(ctx, clients, c.recheckWindow); err != nil {
+			log.Errorf("recheck failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestRecheck(t *testing.T) {
+	testCases := []struct {
+		name          string
+		purge         bool
+		expectDeleted bool
+	}{
+		{name: "mark deleted"},
+		{name: "purge deleted", purge: true, expectDeleted: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				switch strings.TrimPrefix(r.URL.Path, "/api/v1/statuses/") {
+				case "1":
+					fmt.Fprint(w, `{"id":"1","content":"<p>unchanged</p>","edited_at":null}`)
+				case "2":
+					fmt.Fprint(w, `{"id":"2","content":"<p>fixed</p>","tags":[{"name":"flood"}],"edited_at":"2023-07-01T13:00:00Z"}`)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer ts.Close()
+
+			cl := client.NewClient(&mastodon.Config{Server: ts.URL})
+			c := newTestCollector(t)
+			c.clients = []*mastodon.Client{cl}
+			c.SetPurgeDeleted(tc.purge)
+
+			now := time.Now().UTC()
+
+			insertTestStatuses(t, c, ts.URL,
+				&mastodon.Status{ID: "1", URI: "https://a.example/1", Content: "<p>unchanged</p>", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)},
+				&mastodon.Status{ID: "2", URI: "https://a.example/2", Content: "<p>typo</p>", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)},
+				&mastodon.Status{ID: "3", URI: "https://a.example/3", Content: "<p>deleted</p>", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-time.Hour)},
+				&mastodon.Status{ID: "4", URI: "https://a.example/4", Content: "<p>old</p>", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: now.Add(-72 * time.Hour)},
+			)
+
+			summary, err := c.Recheck(context.Background(), 24*time.Hour)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Posts() != 2 {
+				t.Errorf("expected changed posts to match (%v / %v)\n", 2, summary.Posts())
+			}
+
+			results, err := c.Report(context.Background(), ReportOptions{Sort: SortOldest})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, fmt.Sprintf("%s:%s:%s:%v", r.URI[len("https://a.example/"):], r.Content, r.TagList, r.EditedAt != nil))
+			}
+
+			// post 4 was created before the window, and is not rechecked
+			expected := []string{"4:<p>old</p>:outage:false", "1:<p>unchanged</p>:outage:false", "2:<p>fixed</p>:flood:true"}
+
+			if fmt.Sprint(actual) != fmt.Sprint(expected) {
+				t.Errorf("expected posts to match (%v / %v)\n", expected, actual)
+			}
+
+			revisions, err := c.Revisions(context.Background(), "https://a.example/2")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(revisions) != 1 || revisions[0].Content != "<p>typo</p>" || revisions[0].EditedAt != nil {
+				t.Errorf("expected original content as a revision, was: %+v\n", revisions)
+			}
+
+			var count int
+			if err := testStore(c).get(&count, "SELECT COUNT(*) FROM posts WHERE uri = ?", "https://a.example/3"); err != nil {
+				t.Fatal(err)
+			}
+
+			if deleted := count == 0; deleted != tc.expectDeleted {
+				t.Errorf("expected post to be removed: %v, was: %v\n", tc.expectDeleted, deleted)
+			}
+
+			if !tc.purge {
+				// marked posts are not collected again
+				insertTestStatuses(t, c, ts.URL, &mastodon.Status{ID: "3", URI: "https://a.example/3", CreatedAt: now.Add(-time.Hour)})
+
+				if results, _ := c.Report(context.Background(), ReportOptions{}); len(results) != 3 {
+					t.Errorf("expected deleted post to be excluded, was: %d posts\n", len(results))
+				}
+			}
+		})
+	}
+}
+
+func TestRecheckWithoutServerTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// slower than the server timeout, as when waiting out a rate limit
+		time.Sleep(50 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","content":"<p>fixed</p>","edited_at":"2023-07-01T13:00:00Z"}`)
+	}))
+	defer ts.Close()
+
+	c := newTestCollector(t)
+	c.clients = []*mastodon.Client{client.NewClient(&mastodon.Config{Server: ts.URL})}
+	c.serverTimeout = time.Millisecond
+
+	insertTestStatuses(t, c, ts.URL,
+		&mastodon.Status{ID: "1", URI: "https://a.example/1", Content: "<p>typo</p>", CreatedAt: time.Now().UTC().Add(-time.Hour)},
+	)
+
+	summary, err := c.Recheck(context.Background(), 24*time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Posts() != 1 {
+		t.Errorf("expected changed posts to match (%v / %v)\n", 1, summary.Posts())
+	}
+}
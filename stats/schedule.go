@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule determines when a tag is next collected from a server.
+type Schedule interface {
+	// Next returns the time of the next run after t.
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// Every is a schedule which runs at a fixed interval.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func (e Every) String() string {
+	return "every " + time.Duration(e).String()
+}
+
+// busyPosts is the number of new posts in a run, a full page on most
+// servers, at which an adaptive schedule polls more often.
+const busyPosts = 20
+
+// Adaptive is a schedule which polls more often while a tag is busy, and
+// less often while it is quiet, within the bounds of Min and Max. The
+// interval starts at Min, doubles after each run without new posts, and
+// halves after each run with a full page of new posts.
+type Adaptive struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Next returns the time of the next run after t at the shortest interval.
+// Collectors adjust the interval between runs using the posts found.
+func (a Adaptive) Next(t time.Time) time.Time {
+	return t.Add(a.Min)
+}
+
+func (a Adaptive) String() string {
+	return fmt.Sprintf("every %s to %s", a.Min, a.Max)
+}
+
+// adjust returns the interval following a run which found the number of
+// posts, where the previous interval was delay (or zero before the first run).
+func (a Adaptive) adjust(delay time.Duration, posts int) time.Duration {
+	switch {
+	case delay == 0:
+		delay = a.Min
+	case posts == 0:
+		delay *= 2
+	case posts >= busyPosts:
+		delay /= 2
+	}
+
+	if delay < a.Min {
+		delay = a.Min
+	}
+	if delay > a.Max {
+		delay = a.Max
+	}
+	return delay
+}
+
+// cronSchedule runs at the times matching a cron expression.
+type cronSchedule struct {
+	spec     string
+	schedule cron.Schedule
+}
+
+func (cs cronSchedule) Next(t time.Time) time.Time {
+	return cs.schedule.Next(t)
+}
+
+func (cs cronSchedule) String() string {
+	return "at '" + cs.spec + "'"
+}
+
+// ParseSchedule returns the schedule described by spec, which is one of:
+//
+//   - a number of minutes (e.g. 2)
+//   - a duration (e.g. 90s, 5m)
+//   - a range of durations for an adaptive schedule (e.g. 1m..30m)
+//   - a cron expression (e.g. '*/5 * * * *' or '@hourly')
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if minutes, err := strconv.Atoi(spec); err == nil {
+		if minutes <= 0 {
+			return nil, fmt.Errorf("invalid interval: %s (must be positive)", spec)
+		}
+		return Every(time.Duration(minutes) * time.Minute), nil
+	}
+
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid interval: %s (must be positive)", spec)
+		}
+		return Every(d), nil
+	}
+
+	if lo, hi, ok := strings.Cut(spec, ".."); ok {
+		a := Adaptive{}
+		var err error
+
+		if a.Min, err = time.ParseDuration(lo); err != nil {
+			return nil, fmt.Errorf("invalid interval: %s (%v)", spec, err)
+		}
+		if a.Max, err = time.ParseDuration(hi); err != nil {
+			return nil, fmt.Errorf("invalid interval: %s (%v)", spec, err)
+		}
+		if a.Min <= 0 || a.Max < a.Min {
+			return nil, fmt.Errorf("invalid interval: %s (expected min..max)", spec)
+		}
+		return a, nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %s (expected minutes, a duration, min..max or a cron expression)", spec)
+	}
+
+	return cronSchedule{spec: spec, schedule: schedule}, nil
+}
+
+// nextRun returns the time of the run following one which finished at now
+// and found the number of posts. For adaptive schedules, delay holds the
+// interval between runs and is updated.
+func nextRun(s Schedule, delay *time.Duration, posts int, now time.Time) time.Time {
+	if a, ok := s.(Adaptive); ok {
+		*delay = a.adjust(*delay, posts)
+		return now.Add(*delay)
+	}
+	return s.Next(now)
+}
@@ -0,0 +1,112 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 34, 56, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		spec       string
+		expectErr  bool
+		expectNext time.Time
+	}{
+		{name: "minutes", spec: "2", expectNext: now.Add(2 * time.Minute)},
+		{name: "duration", spec: "90s", expectNext: now.Add(90 * time.Second)},
+		{name: "adaptive", spec: "1m..30m", expectNext: now.Add(time.Minute)},
+		{name: "cron", spec: "*/15 * * * *", expectNext: time.Date(2023, 7, 1, 12, 45, 0, 0, time.UTC)},
+		{name: "descriptor", spec: "@hourly", expectNext: time.Date(2023, 7, 1, 13, 0, 0, 0, time.UTC)},
+		{name: "zero minutes", spec: "0", expectErr: true},
+		{name: "negative duration", spec: "-1m", expectErr: true},
+		{name: "inverted range", spec: "30m..1m", expectErr: true},
+		{name: "invalid", spec: "often", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseSchedule(tc.spec)
+
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected error, was: %v\n", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := s.Next(now); !actual.Equal(tc.expectNext) {
+				t.Errorf("expected next run to match (%v / %v)\n", tc.expectNext, actual)
+			}
+		})
+	}
+}
+
+func TestAdaptiveSchedule(t *testing.T) {
+	a := Adaptive{Min: time.Minute, Max: 8 * time.Minute}
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		posts       []int
+		expectDelay time.Duration
+	}{
+		{name: "first run", posts: []int{0}, expectDelay: time.Minute},
+		{name: "quiet", posts: []int{0, 0, 0}, expectDelay: 4 * time.Minute},
+		{name: "bounded by max", posts: []int{0, 0, 0, 0, 0, 0}, expectDelay: 8 * time.Minute},
+		{name: "some posts", posts: []int{0, 0, 5}, expectDelay: 2 * time.Minute},
+		{name: "busy", posts: []int{0, 0, 0, busyPosts}, expectDelay: 2 * time.Minute},
+		{name: "bounded by min", posts: []int{busyPosts, busyPosts}, expectDelay: time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				delay time.Duration
+				next  time.Time
+			)
+
+			for _, n := range tc.posts {
+				next = nextRun(a, &delay, n, now)
+			}
+
+			if delay != tc.expectDelay || !next.Equal(now.Add(tc.expectDelay)) {
+				t.Errorf("expected delay to match (%v / %v)\n", tc.expectDelay, delay)
+			}
+		})
+	}
+}
+
+func TestWatchScheduleFor(t *testing.T) {
+	social := &mastodon.Client{Config: &mastodon.Config{Server: "https://mastodon.social"}}
+	hachyderm := &mastodon.Client{Config: &mastodon.Config{Server: "https://hachyderm.io"}}
+
+	w := Watch{
+		Schedule:        Every(time.Hour),
+		TagSchedules:    map[string]Schedule{"outage": Every(time.Minute)},
+		ServerSchedules: map[string]Schedule{"hachyderm.io": Every(5 * time.Minute)},
+	}
+
+	testCases := []struct {
+		name   string
+		client *mastodon.Client
+		tag    string
+		expect Schedule
+	}{
+		{name: "watch", client: social, tag: "flood", expect: Every(time.Hour)},
+		{name: "server", client: hachyderm, tag: "flood", expect: Every(5 * time.Minute)},
+		{name: "tag", client: social, tag: "Outage", expect: Every(time.Minute)},
+		{name: "tag before server", client: hachyderm, tag: "outage", expect: Every(time.Minute)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := w.scheduleFor(tc.client, tc.tag); actual != tc.expect {
+				t.Errorf("expected schedule to match (%v / %v)\n", tc.expect, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrSearchUnavailable is returned when searching with a binary built
+// without the sqlite FTS5 extension (see 'go build --tags=sqlite_fts5').
+var ErrSearchUnavailable = errors.New("full-text search requires a build with '--tags=sqlite_fts5'")
+
+// searchTriggers keep the content_index in sync with the posts table.
+var searchTriggers = map[string]string{
+	"posts_search_insert": `
+		CREATE TRIGGER posts_search_insert AFTER INSERT ON posts BEGIN
+			INSERT INTO content_index (rowid, content_text) VALUES (new.id, new.content_text);
+		END;`,
+	"posts_search_delete": `
+		CREATE TRIGGER posts_search_delete AFTER DELETE ON posts BEGIN
+			INSERT INTO content_index (content_index, rowid, content_text) VALUES ('delete', old.id, old.content_text);
+		END;`,
+	"posts_search_update": `
+		CREATE TRIGGER posts_search_update AFTER UPDATE OF content_text ON posts BEGIN
+			INSERT INTO content_index (content_index, rowid, content_text) VALUES ('delete', old.id, old.content_text);
+			INSERT INTO content_index (rowid, content_text) VALUES (new.id, new.content_text);
+		END;`,
+}
+
+// SearchOptions filter the results of a full-text search.
+type SearchOptions struct {
+	// Query uses FTS5 syntax: "exact phrase", prefix*, AND / OR / NOT, or
+	// websearch syntax with PostgreSQL: "exact phrase", OR, -excluded
+	Query string
+	Tags  []string
+	Langs []string
+	Limit int
+}
+
+// SearchResult is a post matching a search, with a highlighted snippet
+// of the matching text.
+type SearchResult struct {
+	Status
+	Snippet string  `json:"snippet" db:"snippet"`
+	Rank    float64 `json:"rank" db:"rank"`
+}
+
+// where returns the conditions and arguments for the options, for use in a
+// query where posts are aliased as 'p', and match is a condition matching
+// the search query.
+func (o SearchOptions) where(match string) ([]string, []any) {
+	var (
+		where = []string{match, "p.deleted_at IS NULL"}
+		args  = []any{o.Query}
+	)
+
+	if len(o.Tags) > 0 {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM posts_tags pt
+			JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = p.id AND t.name IN (?)
+		)`)
+		args = append(args, lowerAll(o.Tags))
+	}
+
+	if len(o.Langs) > 0 {
+		where = append(where, "p.lang IN (?)")
+		args = append(args, o.Langs)
+	}
+
+	return where, args
+}
+
+// limit returns the maximum number of results, 20 by default.
+func (o SearchOptions) limit() int {
+	if o.Limit <= 0 {
+		return 20
+	}
+	return o.Limit
+}
+
+// search returns posts matching the query, ordered by relevance (bm25).
+// It returns ErrSearchUnavailable if the search index is not available.
+func (sqliteDialect) search(ctx context.Context, s *sqlStore, opts SearchOptions) ([]*SearchResult, error) {
+	if ok, err := tableExists(s.db, "content_index"); err != nil {
+		return nil, err
+	} else if !ok || !searchAvailable(s.db) {
+		return nil, ErrSearchUnavailable
+	}
+
+	var results []*SearchResult
+
+	where, args := opts.where("content_index MATCH ?")
+	args = append(args, opts.limit())
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+		p.created_at, p.edited_at, p.uri, p.lang, p.content_html as content,
+		`+s.dialect.list(tagListQuery)+` tag_list,
+		snippet(content_index, 0, '**', '**', '…', 16) snippet,
+		bm25(content_index) rank
+		FROM
+			content_index
+		INNER JOIN
+			posts p ON p.id = content_index.rowid
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		ORDER BY rank
+		LIMIT ?;
+	`, args...)
+
+	return results, err
+}
+
+// searchAvailable reports whether the sqlite driver includes FTS5.
+func searchAvailable(db *sqlx.DB) bool {
+	var enabled bool
+	err := db.Get(&enabled, "SELECT sqlite_compileoption_used('ENABLE_FTS5')")
+	return err == nil && enabled
+}
+
+// ensureSearchIndex creates the full-text index of post content if FTS5 is
+// available, rebuilding it if the index may be out of date. Without FTS5, the
+// triggers maintaining an existing index are removed so that posts can still
+// be inserted; the index is rebuilt when opened by a build with FTS5.
+func ensureSearchIndex(db *sqlx.DB) error {
+	indexed, err := tableExists(db, "content_index")
+
+	if err != nil {
+		return err
+	}
+
+	var triggers []string
+
+	if err := db.Select(&triggers, "SELECT name FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'posts_search_%'"); err != nil {
+		return err
+	}
+
+	if !searchAvailable(db) {
+		if len(triggers) > 0 {
+			log.Warn("search index disabled: this build does not include sqlite FTS5")
+		}
+
+		for _, name := range triggers {
+			if _, err := db.Exec("DROP TRIGGER " + name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if indexed && len(triggers) == len(searchTriggers) {
+		return nil
+	}
+
+	log.Info("building search index..")
+
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if !indexed {
+		if _, err := tx.Exec(`CREATE VIRTUAL TABLE content_index USING fts5 (
+			content_text,
+			content = 'posts',
+			content_rowid = 'id'
+		);`); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range triggers {
+		if _, err := tx.Exec("DROP TRIGGER " + name); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range searchTriggers {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO content_index (content_index) VALUES ('rebuild')"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// fillContentText populates the plain text of any posts stored before
+// content_text was maintained by the collector.
+func fillContentText(db *sqlx.DB) error {
+	var rows []struct {
+		ID   int64  `db:"id"`
+		HTML string `db:"content_html"`
+	}
+
+	if err := db.Select(&rows, "SELECT id, content_html FROM posts WHERE content_text IS NULL AND content_html IS NOT NULL"); err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	log.Debugf("extracting text from %d posts", len(rows))
+
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, r := range rows {
+		if _, err := tx.Exec("UPDATE posts SET content_text = ? WHERE id = ?", plainText(r.HTML), r.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func lowerAll(values []string) []string {
+	results := make([]string, len(values))
+	for i, v := range values {
+		results[i] = strings.ToLower(strings.TrimPrefix(v, "#"))
+	}
+	return results
+}
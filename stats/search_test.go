@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestSearch(t *testing.T) {
+	c := newTestCollector(t)
+
+	if !searchAvailable(testStore(c).db) {
+		t.Skip("requires --tags=sqlite_fts5")
+	}
+	if err := ensureSearchIndex(testStore(c).db); err != nil {
+		t.Fatal(err)
+	}
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Content: "<p>Power cut across the city</p>", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+		&mastodon.Status{URI: "https://a.example/2", Content: "<p>Blackout in the east</p>", Language: "de", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+		&mastodon.Status{URI: "https://a.example/3", Content: "<p>My power bill is too high</p>", Tags: []mastodon.Tag{{Name: "energy"}}, CreatedAt: time.Now()},
+	)
+
+	testCases := []struct {
+		name     string
+		input    SearchOptions
+		expected []string
+	}{
+		{
+			name:     "phrase",
+			input:    SearchOptions{Query: `"power cut"`},
+			expected: []string{"https://a.example/1"},
+		},
+		{
+			name:     "prefix",
+			input:    SearchOptions{Query: "black*"},
+			expected: []string{"https://a.example/2"},
+		},
+		{
+			name:     "boolean with tag filter",
+			input:    SearchOptions{Query: "power OR blackout", Tags: []string{"outage"}, Langs: []string{"en"}},
+			expected: []string{"https://a.example/1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := c.Search(context.Background(), tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, r := range results {
+				actual = append(actual, r.URI)
+			}
+
+			if len(actual) != len(tc.expected) || (len(actual) > 0 && actual[0] != tc.expected[0]) {
+				t.Errorf("expected results to match (%v / %v)\n", tc.expected, actual)
+			}
+		})
+	}
+}
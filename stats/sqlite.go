@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// driverSQLite is the database/sql driver of SQLite databases.
+const driverSQLite = "sqlite3"
+
+// sqliteDialect is the SQL of SQLite databases.
+type sqliteDialect struct{}
+
+// bucketFormats are the sqlite strftime formats truncating a date to an interval.
+var bucketFormats = map[string]string{
+	IntervalMinute: "%Y-%m-%d %H:%M:00",
+	IntervalHour:   "%Y-%m-%d %H:00:00",
+	IntervalDay:    "%Y-%m-%d 00:00:00",
+}
+
+func (sqliteDialect) list(query string) string {
+	return "(SELECT COALESCE(group_concat(item), '') FROM (" + query + "))"
+}
+
+func (sqliteDialect) bucket(column, interval string) string {
+	return "strftime('" + bucketFormats[interval] + "', " + column + ")"
+}
+
+func (sqliteDialect) noLimit() string {
+	return "-1"
+}
+
+func (sqliteDialect) size(ctx context.Context, db *sqlx.DB) (int64, error) {
+	var pageCount, pageSize int64
+
+	if err := db.GetContext(ctx, &pageCount, "PRAGMA page_count"); err != nil {
+		return 0, err
+	}
+
+	if err := db.GetContext(ctx, &pageSize, "PRAGMA page_size"); err != nil {
+		return 0, err
+	}
+
+	return pageCount * pageSize, nil
+}
@@ -0,0 +1,283 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-mastodon"
+)
+
+// Store is a database of collected posts. A collector stores posts, and
+// reports on them, through its Store. Stores are implemented for SQLite and
+// PostgreSQL databases, opened with Open.
+type Store interface {
+	// Cursor returns the ID of the newest post stored for the tag on a
+	// server, or an empty ID if the tag has not been collected from it.
+	Cursor(server, tag string) (mastodon.ID, error)
+
+	// SaveCursor records the ID of the newest post stored for the tag on
+	// a server.
+	SaveCursor(server, tag string, lastID mastodon.ID) error
+
+	// InsertStatuses stores any of the statuses which have not been seen
+	// before, and records that the server has seen each of them, in a
+	// single transaction. It returns the posts stored, and the number of
+	// statuses already stored.
+	InsertStatuses(server string, items []*mastodon.Status) ([]*Status, int, error)
+
+	// TagCounts returns the number of posts stored with each of the tags.
+	TagCounts(ctx context.Context, tags []string) ([]TagCount, error)
+
+	// PostsToRecheck returns up to limit posts collected from a server and
+	// created since the cutoff, those checked least recently first.
+	PostsToRecheck(ctx context.Context, server string, cutoff time.Time, limit int) ([]StoredPost, error)
+
+	// UpdatePost stores the engagement counts, edit time and content of a
+	// rechecked post, keeping its prior content as a revision if it has
+	// changed. It reports whether the content changed.
+	UpdatePost(p StoredPost, status *client.Status) (bool, error)
+
+	// RemovePost removes a post deleted by its author. The post is purged if
+	// purge is set, and is otherwise kept without its content, media or
+	// mentions so that it is not collected again.
+	RemovePost(postID int64, purge bool) error
+
+	// Revisions returns the prior content of a post, oldest first.
+	Revisions(ctx context.Context, uri string) ([]*Revision, error)
+
+	// Report returns the posts matching the options.
+	Report(ctx context.Context, opts ReportOptions) ([]*Status, error)
+
+	// Search returns posts matching a full-text search, ordered by relevance.
+	Search(ctx context.Context, opts SearchOptions) ([]*SearchResult, error)
+
+	// TagBuckets returns the number of posts with each tag created within
+	// each interval which has any posts, ordered by tag and interval.
+	TagBuckets(ctx context.Context, opts TrendOptions) ([]TagBucket, error)
+
+	// ServerCounts returns the number of posts with each tag collected from
+	// each server, ordered by server and tag.
+	ServerCounts(ctx context.Context, opts TrendOptions) ([]ServerCount, error)
+
+	// CooccurringTags returns up to limit tags most often used alongside the
+	// requested tags.
+	CooccurringTags(ctx context.Context, opts ReportOptions, limit int) ([]TagCount, error)
+
+	// TagSummaries returns the number of posts with each tag, and when the
+	// latest was created.
+	TagSummaries(ctx context.Context, opts ReportOptions) ([]TagSummary, error)
+
+	// ServerSummaries returns the number of posts collected from each
+	// server, and when the latest was created.
+	ServerSummaries(ctx context.Context, opts ReportOptions) ([]ServerSummary, error)
+
+	// TopPosters returns up to limit accounts with the most posts for each tag.
+	TopPosters(ctx context.Context, opts ReportOptions, limit int) ([]Poster, error)
+
+	// TagReach returns the number of instances the posts with each tag came
+	// from, and of servers they were seen on.
+	TagReach(ctx context.Context, opts ReportOptions) ([]TagReach, error)
+
+	// TopGrowth returns up to limit posts for each tag whose engagement grew
+	// the most since they were collected.
+	TopGrowth(ctx context.Context, opts ReportOptions, limit int) ([]PostGrowth, error)
+
+	// Prune removes posts beyond the retention limits, returning the number
+	// of posts removed, or that would be removed if dryRun is set.
+	Prune(ctx context.Context, r Retention, dryRun bool) (int, error)
+
+	// Star sets whether the post with a URI is kept when the database is
+	// pruned.
+	Star(ctx context.Context, uri string, starred bool) error
+
+	// Optimize updates the statistics used by the query planner and, if
+	// vacuum is set, reclaims unused space.
+	Optimize(ctx context.Context, vacuum bool) error
+
+	// DatabaseSize returns the size of the database in bytes.
+	DatabaseSize(ctx context.Context) (int64, error)
+}
+
+// StoredPost is the state of a post compared when it is rechecked.
+type StoredPost struct {
+	ID       int64           `db:"id"`
+	PostID   mastodon.ID     `db:"post_id"`
+	Content  sql.NullString  `db:"content_html"`
+	EditedAt *sqliteDatetime `db:"edited_at"`
+}
+
+// tagListQuery selects the names of the tags of a post aliased 'p', in order.
+const tagListQuery = `
+	SELECT tt.name AS item
+	FROM posts_tags ptt
+	JOIN tags tt ON tt.id = ptt.tag_id
+	WHERE ptt.post_id = p.id
+	ORDER BY tt.name`
+
+// dialect is the SQL which differs between the databases a sqlStore
+// supports.
+type dialect interface {
+	// list returns an expression joining the values of the single column
+	// selected by query with commas, in order, or an empty string if
+	// there are none.
+	list(query string) string
+
+	// bucket returns an expression formatting the start of the interval
+	// containing a time column, in UTC, as '2006-01-02 15:04:05'.
+	bucket(column, interval string) string
+
+	// noLimit is the LIMIT of a query returning all rows.
+	noLimit() string
+
+	search(ctx context.Context, s *sqlStore, opts SearchOptions) ([]*SearchResult, error)
+	size(ctx context.Context, db *sqlx.DB) (int64, error)
+}
+
+// sqlStore is a Store using a database/sql driver, with the dialect of
+// its database.
+type sqlStore struct {
+	db      *sqlx.DB
+	dialect dialect
+
+	// writes serializes database writes from concurrent workers
+	writes sync.Mutex
+}
+
+// NewStore returns a Store for a database opened with Open.
+func NewStore(db *sqlx.DB) Store {
+	s := &sqlStore{db: db, dialect: sqliteDialect{}}
+
+	if db.DriverName() == driverPostgres {
+		s.dialect = postgresDialect{}
+	}
+	return s
+}
+
+// writeTx is a transaction writing to a sqlStore, which prepares each
+// statement once however many times it is executed.
+type writeTx struct {
+	tx    *sqlx.Tx
+	stmts map[string]*sqlx.Stmt
+
+	// tagIDs caches the IDs of tags stored by the transaction
+	tagIDs map[string]int64
+}
+
+// write calls fn within a transaction, which is committed if fn returns no
+// error and is otherwise rolled back. Writes are serialized.
+func (s *sqlStore) write(fn func(w *writeTx) error) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	tx, err := s.db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	w := &writeTx{tx: tx, stmts: map[string]*sqlx.Stmt{}, tagIDs: map[string]int64{}}
+
+	if err := fn(w); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// prepare returns the statement prepared for a query, preparing it on
+// first use. Statements are closed with the transaction.
+func (w *writeTx) prepare(query string) (*sqlx.Stmt, error) {
+	if stmt, ok := w.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := w.tx.Preparex(w.tx.Rebind(query))
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (w *writeTx) exec(query string, args ...any) (sql.Result, error) {
+	stmt, err := w.prepare(query)
+
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+func (w *writeTx) get(dest any, query string, args ...any) error {
+	stmt, err := w.prepare(query)
+
+	if err != nil {
+		return err
+	}
+	return stmt.Get(dest, args...)
+}
+
+// bind expands any slice arguments of a query, and rebinds its
+// placeholders for the database.
+func (s *sqlStore) bind(query string, args []any) (string, []any, error) {
+	query, args, err := sqlx.In(query, args...)
+
+	if err != nil {
+		return "", nil, err
+	}
+	return s.db.Rebind(query), args, nil
+}
+
+func (s *sqlStore) exec(query string, args ...any) (sql.Result, error) {
+	return s.execContext(context.Background(), query, args...)
+}
+
+func (s *sqlStore) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args, err := s.bind(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *sqlStore) get(dest any, query string, args ...any) error {
+	return s.getContext(context.Background(), dest, query, args...)
+}
+
+func (s *sqlStore) getContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := s.bind(query, args)
+
+	if err != nil {
+		return err
+	}
+	return s.db.GetContext(ctx, dest, query, args...)
+}
+
+func (s *sqlStore) selectContext(ctx context.Context, dest any, query string, args ...any) error {
+	query, args, err := s.bind(query, args)
+
+	if err != nil {
+		return err
+	}
+	return s.db.SelectContext(ctx, dest, query, args...)
+}
+
+// Search returns posts matching the query, ordered by relevance. It returns
+// ErrSearchUnavailable if the database can not be searched.
+func (s *sqlStore) Search(ctx context.Context, opts SearchOptions) ([]*SearchResult, error) {
+	return s.dialect.search(ctx, s, opts)
+}
+
+// DatabaseSize returns the size of the database in bytes.
+func (s *sqlStore) DatabaseSize(ctx context.Context) (int64, error) {
+	return s.dialect.size(ctx, s.db)
+}
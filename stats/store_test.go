@@ -0,0 +1,181 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestStore(t *testing.T) {
+	c := newTestCollector(t)
+	ctx := context.Background()
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{ID: "1", URI: "https://a.example/1", Content: "<p>typo</p>", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: hour.Add(5 * time.Minute)},
+		&mastodon.Status{ID: "2", URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: hour.Add(65 * time.Minute)},
+		&mastodon.Status{ID: "3", URI: "https://a.example/3", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: hour.Add(70 * time.Minute)},
+	)
+
+	t.Run("tag buckets", func(t *testing.T) {
+		buckets, err := c.TagBuckets(ctx, TrendOptions{ReportOptions: ReportOptions{Tags: []string{"outage"}}, Interval: IntervalHour})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := fmt.Sprint([]TagBucket{{"outage", hour, 1}, {"outage", hour.Add(time.Hour), 2}})
+
+		if fmt.Sprint(buckets) != expected {
+			t.Errorf("expected buckets to match (%v / %v)\n", expected, buckets)
+		}
+	})
+
+	t.Run("tag counts", func(t *testing.T) {
+		counts, err := c.TagCounts(ctx, []string{"outage", "power", "missing"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := fmt.Sprint([]TagCount{{Tag: "outage", Count: 3}, {Tag: "power", Count: 1}})
+
+		if fmt.Sprint(counts) != expected {
+			t.Errorf("expected counts to match (%v / %v)\n", expected, counts)
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		if err := c.SaveCursor("https://mastodon.social", "Outage", "3"); err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := c.Cursor("https://mastodon.social", "outage")
+
+		if err != nil || actual != "3" {
+			t.Errorf("expected cursor to match (%v / %v), error: %v\n", "3", actual, err)
+		}
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		posts, err := c.PostsToRecheck(ctx, "https://mastodon.social", hour, 1)
+
+		if err != nil || len(posts) != 1 {
+			t.Fatalf("expected a post to recheck, was: %v (%v)\n", posts, err)
+		}
+
+		editedAt := time.Now().UTC().Truncate(time.Second)
+		status := &client.Status{Status: mastodon.Status{Content: "<p>fixed</p>", Tags: []mastodon.Tag{{Name: "power"}}}, EditedAt: &editedAt}
+
+		if changed, err := c.UpdatePost(posts[0], status); err != nil || !changed {
+			t.Fatalf("expected post to change, was: %v (%v)\n", changed, err)
+		}
+
+		revisions, err := c.Revisions(ctx, "https://a.example/1")
+
+		if err != nil || len(revisions) != 1 || revisions[0].Content != "<p>typo</p>" {
+			t.Errorf("expected the original content as a revision, was: %v (%v)\n", revisions, err)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		if size, err := c.DatabaseSize(ctx); err != nil || size <= 0 {
+			t.Errorf("expected a database size, was: %d (%v)\n", size, err)
+		}
+	})
+}
+
+// testStore returns the store of a collector created by newTestCollector.
+func testStore(c *Collector) *sqlStore {
+	return c.Store.(*sqlStore)
+}
+
+// openPostgresTestDB opens the PostgreSQL database named by dsn, using a new
+// schema which is dropped when the test completes.
+func openPostgresTestDB(t *testing.T, dsn string) *sqlx.DB {
+	t.Helper()
+
+	admin, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := fmt.Sprintf("proma_test_%d", time.Now().UnixNano())
+	admin.MustExec("CREATE SCHEMA " + schema)
+
+	db, err := Open(withParam(dsn, "search_path", schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		admin.MustExec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+	return db
+}
+
+func TestInsertStatuses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		setup           string
+		expectInserted  int
+		expectSkipped   int
+		expectErr       bool
+		expectSightings int
+	}{
+		{
+			name:            "duplicates in a page",
+			expectInserted:  2,
+			expectSkipped:   1,
+			expectSightings: 2,
+		},
+		{
+			name:      "rolled back on error",
+			setup:     "DROP TABLE post_engagement",
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCollector(t)
+
+			if tc.setup != "" {
+				testStore(c).db.MustExec(tc.setup)
+			}
+
+			inserted, skipped, err := c.InsertStatuses("https://mastodon.social", []*mastodon.Status{
+				{ID: "1", URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+				{ID: "2", URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+				{ID: "1", URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: time.Now()},
+			})
+
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %v, was: %v\n", tc.expectErr, err)
+			}
+
+			if len(inserted) != tc.expectInserted || skipped != tc.expectSkipped {
+				t.Errorf("expected inserted and skipped to match (%v, %v / %v, %v)\n", tc.expectInserted, tc.expectSkipped, len(inserted), skipped)
+			}
+
+			var posts, sightings int
+
+			if err := testStore(c).get(&posts, "SELECT COUNT(*) FROM posts"); err != nil {
+				t.Fatal(err)
+			}
+			if err := testStore(c).get(&sightings, "SELECT COUNT(*) FROM post_sightings"); err != nil {
+				t.Fatal(err)
+			}
+
+			if posts != tc.expectInserted || sightings != tc.expectSightings {
+				t.Errorf("expected posts and sightings to match (%v, %v / %v, %v)\n", tc.expectInserted, tc.expectSightings, posts, sightings)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StartStream subscribes to the hashtag stream of each server for the
+// provided tags, storing posts as they arrive. Posts missed while a stream is
+// disconnected are collected on reconnect. Servers which do not allow
+// streaming are polled on the collector's schedule instead.
+// It will shut down when Stop() is called.
+func (c *Collector) StartStream(ctx context.Context, tagNames []string, local bool) {
+	log.Infof("collector streaming %d tags from %d servers", len(tagNames), len(c.clients))
+	c.stop = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+
+	for _, cl := range c.clients {
+		for _, tag := range tagNames {
+			wg.Add(1)
+
+			go func(cl *mastodon.Client, tag string) {
+				defer wg.Done()
+				c.streamTag(ctx, cl, tag, local)
+			}(cl, tag)
+		}
+	}
+
+	c.startRecheck(ctx, &wg, c.clients)
+	c.startMaintenance(ctx, &wg)
+
+	go func() {
+		<-c.stop
+		log.Debug("collector shutting down..")
+
+		cancel()
+		wg.Wait()
+
+		c.stop <- struct{}{} // signals back to Stop()
+	}()
+}
+
+// streamTag streams a single tag from a server until the context is
+// canceled, falling back to polling if streaming is unavailable.
+func (c *Collector) streamTag(ctx context.Context, cl *mastodon.Client, tag string, local bool) {
+	server := cl.Config.Server
+	timelineFeed := client.ServerFeed(ctx, cl)
+
+	s := &client.HashtagStream{
+		Client: cl,
+		Tag:    tag,
+		Local:  local,
+		OnConnect: func() {
+			c.pollTag(cl, tag, timelineFeed)
+		},
+		OnUpdate: func(status *mastodon.Status) {
+			if _, err := c.insertStatuses(server, tag, []*mastodon.Status{status}); err != nil {
+				log.Errorf("error storing streamed post from %s: %v", server, err)
+				return
+			}
+
+			if err := c.advanceCursor(server, tag, status.ID); err != nil {
+				log.Errorf("error updating cursor for %s: %v", server, err)
+			}
+		},
+	}
+
+	err := s.Run(ctx)
+
+	if !errors.Is(err, client.ErrStreamingUnavailable) {
+		return
+	}
+
+	log.Warnf("%v for %s, polling '%s' %s", err, server, tag, c.schedule)
+
+	var delay time.Duration
+
+	for {
+		n := c.pollTag(cl, tag, timelineFeed)
+		timer := time.NewTimer(time.Until(nextRun(c.schedule, &delay, n, time.Now())))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// pollTag collects any posts for tag newer than the stored cursor, and
+// records the result in the server's health. It returns the number of
+// posts stored.
+func (c *Collector) pollTag(cl *mastodon.Client, tag string, timelineFeed client.TagTimeline) int {
+	started := time.Now()
+	n, err := c.collectTag(cl, tag, timelineFeed)
+
+	if err != nil {
+		log.Errorf("error collecting '%s' from %s: %v\n", tag, cl.Config.Server, err)
+	}
+
+	c.record(ServerResult{
+		Server:   cl.Config.Server,
+		Posts:    n,
+		Duration: time.Since(started),
+		Err:      err,
+	}, started)
+
+	return n
+}
+
+// advanceCursor moves the cursor for the tag on a server to lastID, if it
+// is more recent than the stored cursor.
+func (c *Collector) advanceCursor(server, tag string, lastID mastodon.ID) error {
+	cursor, err := c.Cursor(server, tag)
+
+	if err != nil || !client.NewerID(lastID, cursor) {
+		return err
+	}
+
+	return c.SaveCursor(server, tag, lastID)
+}
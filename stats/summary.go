@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServerResult is the outcome of collecting from a single server.
+type ServerResult struct {
+	Server   string        `json:"server"`
+	Posts    int           `json:"posts"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+}
+
+// RunSummary is the outcome of a single collection run across all servers.
+type RunSummary struct {
+	Started  time.Time      `json:"started"`
+	Duration time.Duration  `json:"duration"`
+	Servers  []ServerResult `json:"servers"`
+}
+
+// Posts returns the number of posts stored across all servers.
+func (s *RunSummary) Posts() int {
+	var n int
+	for _, r := range s.Servers {
+		n += r.Posts
+	}
+	return n
+}
+
+// Failed returns the results for any servers which returned an error.
+func (s *RunSummary) Failed() []ServerResult {
+	var failed []ServerResult
+	for _, r := range s.Servers {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Err returns an error if collection failed for every server in the run,
+// or nil if at least one server was collected successfully.
+func (s *RunSummary) Err() error {
+	failed := s.Failed()
+
+	if len(failed) == 0 || len(failed) < len(s.Servers) {
+		return nil
+	}
+
+	return fmt.Errorf("collection failed for all %d servers (%s: %w)", len(failed), failed[0].Server, failed[0].Err)
+}
+
+func (s *RunSummary) String() string {
+	return fmt.Sprintf("stored %d posts from %d servers in %s (%d failed)",
+		s.Posts(), len(s.Servers), s.Duration.Round(time.Millisecond), len(s.Failed()))
+}
+
+// ServerHealth accumulates collection results for a server across runs.
+type ServerHealth struct {
+	Server              string    `json:"server"`
+	Runs                int       `json:"runs"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Posts               int       `json:"posts"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastRun             time.Time `json:"last_run"`
+	LastSuccess         time.Time `json:"last_success"`
+}
+
+// healthTracker records ServerHealth for each server a collector polls.
+type healthTracker struct {
+	mu      sync.Mutex
+	servers map[string]*ServerHealth
+}
+
+func (h *healthTracker) record(r ServerResult, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.servers == nil {
+		h.servers = map[string]*ServerHealth{}
+	}
+
+	sh, ok := h.servers[r.Server]
+
+	if !ok {
+		sh = &ServerHealth{Server: r.Server}
+		h.servers[r.Server] = sh
+	}
+
+	sh.Runs++
+	sh.Posts += r.Posts
+	sh.LastRun = at
+
+	if r.Err != nil {
+		sh.Failures++
+		sh.ConsecutiveFailures++
+		sh.LastError = r.Err.Error()
+	} else {
+		sh.ConsecutiveFailures = 0
+		sh.LastSuccess = at
+	}
+}
+
+// snapshot returns a copy of the health of all servers, ordered by name.
+func (h *healthTracker) snapshot() []ServerHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	results := make([]ServerHealth, 0, len(h.servers))
+
+	for _, sh := range h.servers {
+		results = append(results, *sh)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Server < results[j].Server
+	})
+
+	return results
+}
@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunSummaryErr(t *testing.T) {
+	failure := errors.New("bad request: 503 Service Unavailable")
+
+	testCases := []struct {
+		name      string
+		input     []ServerResult
+		expectErr bool
+	}{
+		{
+			name:      "no servers",
+			expectErr: false,
+		},
+		{
+			name: "partial failure",
+			input: []ServerResult{
+				{Server: "https://a.example", Posts: 2},
+				{Server: "https://b.example", Err: failure},
+			},
+			expectErr: false,
+		},
+		{
+			name: "all failed",
+			input: []ServerResult{
+				{Server: "https://a.example", Err: failure},
+				{Server: "https://b.example", Err: failure},
+			},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			summary := &RunSummary{Servers: tc.input}
+			err := summary.Err()
+
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error: %v, was: %v\n", tc.expectErr, err)
+			}
+			if err != nil && !errors.Is(err, failure) {
+				t.Errorf("expected error to wrap server error, was: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestHealthTracker(t *testing.T) {
+	h := healthTracker{}
+	now := time.Now()
+
+	h.record(ServerResult{Server: "https://a.example", Posts: 3}, now)
+	h.record(ServerResult{Server: "https://a.example", Err: errors.New("timeout")}, now)
+	h.record(ServerResult{Server: "https://a.example", Err: errors.New("timeout")}, now)
+
+	actual := h.snapshot()
+
+	if len(actual) != 1 {
+		t.Fatalf("expected 1 server, was %d\n", len(actual))
+	}
+	if actual[0].Runs != 3 || actual[0].Failures != 2 || actual[0].ConsecutiveFailures != 2 {
+		t.Errorf("unexpected health: %+v\n", actual[0])
+	}
+	if actual[0].Posts != 3 || !actual[0].LastSuccess.Equal(now) {
+		t.Errorf("unexpected health: %+v\n", actual[0])
+	}
+}
@@ -0,0 +1,111 @@
+package stats
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// plainText strips the HTML from a post's content, keeping line breaks
+// between paragraphs.
+func plainText(html string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+
+	if err != nil {
+		return ""
+	}
+
+	doc.Find("br").ReplaceWithHtml("\n")
+	doc.Find("p").Each(func(i int, s *goquery.Selection) {
+		s.AppendHtml("\n\n")
+	})
+
+	lines := strings.Split(doc.Text(), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	text := strings.Join(lines, "\n")
+
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// allowedElements are the HTML elements kept by sanitizeHTML. Any other
+// elements are removed, keeping their text.
+var allowedElements = map[string]bool{
+	"p": true, "br": true, "a": true, "span": true,
+	"b": true, "strong": true, "i": true, "em": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "code": true, "pre": true,
+}
+
+// sanitizeHTML returns post content which is safe to embed in a page,
+// keeping basic formatting and links to http(s) URLs.
+func sanitizeHTML(content string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+
+	if err != nil {
+		return html.EscapeString(plainText(content))
+	}
+
+	var b strings.Builder
+
+	for _, n := range nodes {
+		writeSanitized(&b, n)
+	}
+	return b.String()
+}
+
+func writeSanitized(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Iframe, atom.Object, atom.Template:
+		return
+	}
+
+	allowed := allowedElements[n.Data]
+
+	if allowed {
+		b.WriteString("<" + n.Data)
+
+		if n.DataAtom == atom.A {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" && (strings.HasPrefix(attr.Val, "https://") || strings.HasPrefix(attr.Val, "http://")) {
+					b.WriteString(` href="` + html.EscapeString(attr.Val) + `" rel="nofollow noopener noreferrer" target="_blank"`)
+				}
+			}
+		}
+
+		b.WriteString(">")
+
+		if n.DataAtom == atom.Br {
+			return
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeSanitized(b, child)
+	}
+
+	if allowed {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
@@ -0,0 +1,77 @@
+package stats
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: "",
+		},
+		{
+			name:     "paragraphs",
+			input:    "<p>Upside: no storms.</p><p>Downside: no internet.</p>",
+			expected: "Upside: no storms.\n\nDownside: no internet.",
+		},
+		{
+			name:     "line breaks and hashtags",
+			input:    `<p>power is out<br>again <a href="https://mastodon.social/tags/outage" class="mention hashtag" rel="tag">#<span>outage</span></a></p>`,
+			expected: "power is out\nagain #outage",
+		},
+		{
+			name:     "entities",
+			input:    "<p>&quot;HARUMPH&quot; &amp; more</p>",
+			expected: `"HARUMPH" & more`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := plainText(tc.input)
+			if actual != tc.expected {
+				t.Errorf("expected text to match (%q / %q)\n", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "formatting and links",
+			input:    `<p>power <b>out</b><br><a href="https://mastodon.social/tags/outage" class="mention hashtag">#<span>outage</span></a></p>`,
+			expected: `<p>power <b>out</b><br><a href="https://mastodon.social/tags/outage" rel="nofollow noopener noreferrer" target="_blank">#<span>outage</span></a></p>`,
+		},
+		{
+			name:     "scripts and handlers",
+			input:    `<p onclick="alert(1)">hi<script>alert(2)</script><img src=x onerror="alert(3)"></p>`,
+			expected: `<p>hi</p>`,
+		},
+		{
+			name:     "unsafe link",
+			input:    `<a href="javascript:alert(1)">click</a>`,
+			expected: `<a>click</a>`,
+		},
+		{
+			name:     "unknown elements keep text",
+			input:    `<div><h1>Title &amp; &lt;more&gt;</h1></div>`,
+			expected: `Title &amp; &lt;more&gt;`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := sanitizeHTML(tc.input)
+			if actual != tc.expected {
+				t.Errorf("expected HTML to match (%q / %q)\n", tc.expected, actual)
+			}
+		})
+	}
+}
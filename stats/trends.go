@@ -0,0 +1,396 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Intervals used to bucket post counts.
+const (
+	IntervalMinute = "minute"
+	IntervalHour   = "hour"
+	IntervalDay    = "day"
+)
+
+var intervalDurations = map[string]time.Duration{
+	IntervalMinute: time.Minute,
+	IntervalHour:   time.Hour,
+	IntervalDay:    24 * time.Hour,
+}
+
+// TrendOptions select the posts counted by TagTrends and ServerCounts.
+type TrendOptions struct {
+	ReportOptions
+
+	// Interval is the width of each bucket (minute, hour or day).
+	Interval string
+
+	// Window is the number of preceding buckets used for rolling averages.
+	Window int
+
+	// Threshold is the z-score at or above which a bucket is a spike.
+	Threshold float64
+}
+
+// Bucket is the number of posts with a tag created within an interval.
+type Bucket struct {
+	Start    time.Time `json:"start"`
+	Count    int       `json:"count"`
+	Velocity int       `json:"velocity"`
+	Average  float64   `json:"average"`
+	ZScore   float64   `json:"z_score"`
+	Spike    bool      `json:"spike"`
+}
+
+// TagTrend is the series of post counts for a tag.
+type TagTrend struct {
+	Tag     string   `json:"tag"`
+	Total   int      `json:"total"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Spikes returns the buckets detected as spikes.
+func (t TagTrend) Spikes() []Bucket {
+	var results []Bucket
+	for _, b := range t.Buckets {
+		if b.Spike {
+			results = append(results, b)
+		}
+	}
+	return results
+}
+
+// ServerCount is the number of posts with a tag collected from a server.
+type ServerCount struct {
+	Server string `json:"server" db:"server"`
+	Tag    string `json:"tag" db:"tag"`
+	Count  int    `json:"count" db:"count"`
+}
+
+// TagBucket is the number of posts with a tag created within the interval
+// starting at Start.
+type TagBucket struct {
+	Tag   string
+	Start time.Time
+	Count int
+}
+
+// TagBuckets returns the number of posts with each tag created within each
+// interval which has any posts, ordered by tag and interval.
+func (s *sqlStore) TagBuckets(ctx context.Context, opts TrendOptions) ([]TagBucket, error) {
+	if _, ok := intervalDurations[opts.Interval]; !ok {
+		return nil, fmt.Errorf("unknown interval: %s (expected minute, hour or day)", opts.Interval)
+	}
+
+	var rows []struct {
+		Tag    string `db:"tag"`
+		Bucket string `db:"bucket"`
+		Count  int    `db:"count"`
+	}
+
+	where, args := opts.tagWhere()
+
+	err := s.selectContext(ctx, &rows, `
+		SELECT
+			t.name tag,
+			`+s.dialect.bucket("p.created_at", opts.Interval)+` bucket,
+			COUNT(*) count
+		FROM
+			posts p
+		INNER JOIN
+			posts_tags pt ON pt.post_id = p.id
+		INNER JOIN
+			tags t ON t.id = pt.tag_id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY tag, bucket
+		ORDER BY tag, bucket;
+	`, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]TagBucket, len(rows))
+
+	for i, r := range rows {
+		start, err := time.ParseInLocation("2006-01-02 15:04:05", r.Bucket, time.UTC)
+
+		if err != nil {
+			return nil, err
+		}
+		results[i] = TagBucket{Tag: r.Tag, Start: start, Count: r.Count}
+	}
+
+	return results, nil
+}
+
+// TagTrends returns post counts for each tag, bucketed by interval, with
+// rolling averages and spikes detected over the preceding window.
+// Intervals without posts are included with a count of zero.
+func (c *Collector) TagTrends(ctx context.Context, opts TrendOptions) ([]TagTrend, error) {
+	rows, err := c.TagBuckets(ctx, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]map[time.Time]int{}
+	var first, last time.Time
+
+	for _, r := range rows {
+		start := r.Start
+
+		if counts[r.Tag] == nil {
+			counts[r.Tag] = map[time.Time]int{}
+		}
+		counts[r.Tag][start] = r.Count
+
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+
+	step := intervalDurations[opts.Interval]
+
+	if !opts.From.IsZero() {
+		first = truncate(opts.From.UTC(), opts.Interval)
+	}
+	if !opts.To.IsZero() {
+		last = truncate(opts.To.UTC().Add(-time.Nanosecond), opts.Interval)
+	} else if now := truncate(time.Now().UTC(), opts.Interval); now.After(last) {
+		// the series runs to the current interval, however quiet
+		last = now
+	}
+
+	var results []TagTrend
+
+	for tag, byStart := range counts {
+		trend := TagTrend{Tag: tag}
+
+		for start := first; !start.After(last); start = nextBucket(start, opts.Interval, step) {
+			n := byStart[start]
+			trend.Total += n
+			trend.Buckets = append(trend.Buckets, Bucket{Start: start, Count: n})
+		}
+
+		detectSpikes(trend.Buckets, opts.Window, opts.Threshold)
+		results = append(results, trend)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Tag < results[j].Tag
+	})
+
+	return results, nil
+}
+
+// ServerCounts returns the number of posts with each tag collected from
+// each server, ordered by server and tag. Posts seen on several servers are
+// counted for each of them.
+func (s *sqlStore) ServerCounts(ctx context.Context, opts TrendOptions) ([]ServerCount, error) {
+	var results []ServerCount
+
+	where, args := opts.tagWhere()
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			ps.server server,
+			t.name tag,
+			COUNT(*) count
+		FROM
+			posts p
+		INNER JOIN
+			post_sightings ps ON ps.post_id = p.id
+		INNER JOIN
+			posts_tags pt ON pt.post_id = p.id
+		INNER JOIN
+			tags t ON t.id = pt.tag_id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY ps.server, tag
+		ORDER BY ps.server, tag;
+	`, args...)
+
+	return results, err
+}
+
+// tagWhere returns conditions for the options, where posts are aliased 'p'
+// and their tags 't'. Counts are limited to the requested tags, if any.
+func (o TrendOptions) tagWhere() ([]string, []any) {
+	where, args := o.ReportOptions.where()
+
+	if len(o.Tags) > 0 {
+		where = append(where, "t.name IN (?)")
+		args = append(args, lowerAll(o.Tags))
+	}
+	return where, args
+}
+
+// detectSpikes sets the velocity, rolling average and z-score of each bucket,
+// comparing it with up to window preceding buckets. A bucket is a spike when
+// its z-score is at least threshold; at least 3 preceding buckets are needed.
+// The standard deviation is floored at 1, so quiet tags need several
+// posts in a bucket to register a spike.
+func detectSpikes(buckets []Bucket, window int, threshold float64) {
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].Velocity = buckets[i].Count - buckets[i-1].Count
+		}
+
+		from := i - window
+		if from < 0 {
+			from = 0
+		}
+
+		history := buckets[from:i]
+
+		if len(history) == 0 {
+			continue
+		}
+
+		var sum float64
+		for _, b := range history {
+			sum += float64(b.Count)
+		}
+		mean := sum / float64(len(history))
+
+		var variance float64
+		for _, b := range history {
+			variance += math.Pow(float64(b.Count)-mean, 2)
+		}
+		stddev := math.Sqrt(variance / float64(len(history)))
+
+		buckets[i].Average = mean
+		buckets[i].ZScore = (float64(buckets[i].Count) - mean) / math.Max(stddev, 1)
+		buckets[i].Spike = len(history) >= 3 && threshold > 0 && buckets[i].ZScore >= threshold
+	}
+}
+
+// truncate returns the start of the interval containing t.
+func truncate(t time.Time, interval string) time.Time {
+	if interval == IntervalDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(intervalDurations[interval])
+}
+
+func nextBucket(t time.Time, interval string, step time.Duration) time.Time {
+	if interval == IntervalDay {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(step)
+}
+
+// TagCount is the number of posts with a tag.
+type TagCount struct {
+	Tag   string `json:"tag" db:"tag"`
+	Count int    `json:"count" db:"count"`
+}
+
+// CooccurringTags returns the tags most often used alongside the requested
+// tags, ordered by the number of posts using both.
+func (s *sqlStore) CooccurringTags(ctx context.Context, opts ReportOptions, limit int) ([]TagCount, error) {
+	var results []TagCount
+
+	if len(opts.Tags) == 0 {
+		return results, nil
+	}
+
+	where, args := opts.where()
+	where = append(where, "other.name NOT IN (?)")
+	args = append(args, lowerAll(opts.Tags), limit)
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			other.name tag,
+			COUNT(DISTINCT p.id) count
+		FROM
+			posts p
+		INNER JOIN
+			posts_tags opt ON opt.post_id = p.id
+		INNER JOIN
+			tags other ON other.id = opt.tag_id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY tag
+		ORDER BY count DESC, tag
+		LIMIT ?;
+	`, args...)
+
+	return results, err
+}
+
+// TagSummary is the number of posts collected with a tag.
+type TagSummary struct {
+	Tag        string         `json:"tag" db:"tag"`
+	Count      int            `json:"count" db:"count"`
+	LastPostAt sqliteDatetime `json:"last_post_at" db:"last_post_at"`
+}
+
+// TagSummaries returns the number of posts with each tag, and the creation
+// date of the latest one, ordered by tag.
+func (s *sqlStore) TagSummaries(ctx context.Context, opts ReportOptions) ([]TagSummary, error) {
+	var results []TagSummary
+
+	where, args := TrendOptions{ReportOptions: opts}.tagWhere()
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			t.name tag,
+			COUNT(*) count,
+			MAX(p.created_at) last_post_at
+		FROM
+			posts p
+		INNER JOIN
+			posts_tags pt ON pt.post_id = p.id
+		INNER JOIN
+			tags t ON t.id = pt.tag_id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY tag
+		ORDER BY tag;
+	`, args...)
+
+	return results, err
+}
+
+// ServerSummary is the number of posts collected from a server.
+type ServerSummary struct {
+	Server     string         `json:"server" db:"server"`
+	Count      int            `json:"count" db:"count"`
+	LastPostAt sqliteDatetime `json:"last_post_at" db:"last_post_at"`
+}
+
+// ServerSummaries returns the number of posts collected from each server,
+// and the creation date of the latest one, ordered by server. Posts seen on
+// several servers are counted for each of them.
+func (s *sqlStore) ServerSummaries(ctx context.Context, opts ReportOptions) ([]ServerSummary, error) {
+	var results []ServerSummary
+
+	where, args := opts.where()
+
+	err := s.selectContext(ctx, &results, `
+		SELECT
+			ps.server server,
+			COUNT(*) count,
+			MAX(p.created_at) last_post_at
+		FROM
+			posts p
+		INNER JOIN
+			post_sightings ps ON ps.post_id = p.id
+		WHERE
+			`+strings.Join(where, " AND ")+`
+		GROUP BY ps.server
+		ORDER BY ps.server;
+	`, args...)
+
+	return results, err
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestDetectSpikes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        []int
+		window       int
+		expectSpikes []int
+	}{
+		{
+			name:   "flat",
+			input:  []int{2, 2, 2, 2, 2},
+			window: 4,
+		},
+		{
+			name:         "jump after quiet period",
+			input:        []int{1, 0, 2, 1, 12, 3},
+			window:       4,
+			expectSpikes: []int{4},
+		},
+		{
+			name:   "not enough history",
+			input:  []int{0, 0, 12},
+			window: 4,
+		},
+		{
+			name:         "window excludes old peaks",
+			input:        []int{30, 1, 1, 1, 1, 9},
+			window:       4,
+			expectSpikes: []int{5},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buckets := make([]Bucket, len(tc.input))
+			for i, n := range tc.input {
+				buckets[i].Count = n
+			}
+
+			detectSpikes(buckets, tc.window, 3)
+
+			var actual []int
+			for i, b := range buckets {
+				if b.Spike {
+					actual = append(actual, i)
+				}
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tc.expectSpikes) {
+				t.Errorf("expected spikes to match (%v / %v)\n", tc.expectSpikes, actual)
+			}
+		})
+	}
+}
+
+func TestTagTrends(t *testing.T) {
+	c := newTestCollector(t)
+	start := time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: start.Add(5 * time.Minute)},
+		&mastodon.Status{URI: "https://a.example/2", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: start.Add(50 * time.Minute)},
+		&mastodon.Status{URI: "https://a.example/3", Tags: []mastodon.Tag{{Name: "outage"}, {Name: "power"}}, CreatedAt: start.Add(150 * time.Minute)},
+	)
+
+	opts := TrendOptions{
+		ReportOptions: ReportOptions{Tags: []string{"outage"}, From: start, To: start.Add(4 * time.Hour)},
+		Interval:      IntervalHour,
+		Window:        3,
+		Threshold:     3,
+	}
+
+	trends, err := c.TagTrends(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trends) != 1 || trends[0].Tag != "outage" || trends[0].Total != 3 {
+		t.Fatalf("unexpected trends: %+v\n", trends)
+	}
+
+	var actual []int
+	for _, b := range trends[0].Buckets {
+		actual = append(actual, b.Count)
+	}
+
+	if expected := []int{2, 0, 1, 0}; fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected counts to match (%v / %v)\n", expected, actual)
+	}
+
+	counts, err := c.ServerCounts(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(counts) != 1 || counts[0].Count != 3 || counts[0].Server != "https://mastodon.social" {
+		t.Errorf("unexpected server counts: %+v\n", counts)
+	}
+}
+
+func TestTagTrendsToNow(t *testing.T) {
+	c := newTestCollector(t)
+	now := time.Now().UTC()
+	from := now.Add(-5 * time.Hour).Truncate(time.Hour)
+
+	insertTestStatuses(t, c, "https://mastodon.social",
+		&mastodon.Status{URI: "https://a.example/1", Tags: []mastodon.Tag{{Name: "outage"}}, CreatedAt: from.Add(10 * time.Minute)},
+	)
+
+	opts := TrendOptions{
+		ReportOptions: ReportOptions{Tags: []string{"outage"}, From: from},
+		Interval:      IntervalHour,
+		Window:        3,
+		Threshold:     3,
+	}
+
+	trends, err := c.TagTrends(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trends) != 1 || trends[0].Total != 1 {
+		t.Fatalf("unexpected trends: %+v\n", trends)
+	}
+
+	// quiet intervals run up to the current one
+	buckets := trends[0].Buckets
+
+	if last := buckets[len(buckets)-1].Start; last.Before(now.Truncate(time.Hour)) {
+		t.Errorf("expected buckets up to the current interval (%v / %v)\n", now.Truncate(time.Hour), last)
+	}
+
+	if buckets[0].Count != 1 || len(buckets) < 6 {
+		t.Errorf("unexpected buckets: %+v\n", buckets)
+	}
+}
@@ -0,0 +1,199 @@
+package stats
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Watch is a named set of tags collected from servers on a schedule.
+type Watch struct {
+	Name    string
+	Tags    []string
+	Clients []*mastodon.Client
+
+	// Schedule of collection runs, or the collector's schedule if nil.
+	Schedule Schedule
+
+	// TagSchedules and ServerSchedules override the schedule for a tag, or
+	// for a server by name. A tag's schedule takes precedence.
+	TagSchedules    map[string]Schedule
+	ServerSchedules map[string]Schedule
+}
+
+// scheduleFor returns the schedule for collecting the tag from a server.
+func (w Watch) scheduleFor(cl *mastodon.Client, tag string) Schedule {
+	for t, s := range w.TagSchedules {
+		if strings.EqualFold(t, tag) {
+			return s
+		}
+	}
+
+	for server, s := range w.ServerSchedules {
+		if serverURL(server) == cl.Config.Server {
+			return s
+		}
+	}
+
+	return w.Schedule
+}
+
+// StartWatches runs each of the watches in a loop, on its own schedule. It
+// will shut down when Stop() is called. Failed runs are logged, and
+// collection continues on the next tick.
+func (c *Collector) StartWatches(ctx context.Context, watches []Watch) {
+	c.stop = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+
+	var (
+		clients []*mastodon.Client
+		servers = map[string]bool{}
+	)
+
+	for _, w := range watches {
+		for _, cl := range w.Clients {
+			if !servers[cl.Config.Server] {
+				servers[cl.Config.Server] = true
+				clients = append(clients, cl)
+			}
+		}
+
+		if w.Schedule == nil {
+			w.Schedule = c.schedule
+		}
+
+		if w.Name == "" {
+			log.Infof("collector started. will refresh %s", w.Schedule)
+		} else {
+			log.Infof("watch '%s' started. will refresh %s", w.Name, w.Schedule)
+		}
+
+		wg.Add(1)
+
+		go func(w Watch) {
+			defer wg.Done()
+			c.runWatch(ctx, w)
+		}(w)
+	}
+
+	c.startRecheck(ctx, &wg, clients)
+	c.startMaintenance(ctx, &wg)
+
+	go func() {
+		<-c.stop
+		log.Debug("collector shutting down..")
+
+		cancel()
+		wg.Wait()
+
+		c.stop <- struct{}{} // signals back to Stop()
+	}()
+}
+
+// BackfillWatch collects posts for the tags of a watch back to the cutoff.
+func (c *Collector) BackfillWatch(ctx context.Context, w Watch, cutoff time.Time) (*RunSummary, error) {
+	return c.backfill(ctx, w.Clients, w.Tags, cutoff)
+}
+
+// watchTarget is a tag collected from a server by a watch.
+type watchTarget struct {
+	client *mastodon.Client
+	tag    string
+}
+
+// runWatch collects each tag of a watch from each server when its schedule
+// is due, until the context is canceled. Tags due at the same time are
+// collected in a single run.
+func (c *Collector) runWatch(ctx context.Context, w Watch) {
+	var (
+		targets []watchTarget
+		next    = map[watchTarget]time.Time{}
+		delays  = map[watchTarget]time.Duration{}
+	)
+
+	for _, cl := range w.Clients {
+		for _, tag := range w.Tags {
+			targets = append(targets, watchTarget{cl, tag})
+		}
+	}
+
+	if len(targets) == 0 {
+		// nothing to collect, e.g. when only serving collected posts
+		<-ctx.Done()
+		return
+	}
+
+	for {
+		var (
+			now     = time.Now()
+			clients []*mastodon.Client
+			due     = map[*mastodon.Client][]string{}
+		)
+
+		for _, t := range targets {
+			if next[t].After(now) {
+				continue
+			}
+			if len(due[t.client]) == 0 {
+				clients = append(clients, t.client)
+			}
+			due[t.client] = append(due[t.client], t.tag)
+		}
+
+		log.Debugf("collector run starting %s", w.Name)
+
+		var (
+			mu    sync.Mutex
+			posts = map[watchTarget]int{}
+		)
+
+		_, err := c.collectTags(ctx, clients,
+			func(cl *mastodon.Client) []string { return due[cl] },
+			func(cl *mastodon.Client, tag string, n int) {
+				mu.Lock()
+				posts[watchTarget{cl, tag}] = n
+				mu.Unlock()
+			})
+
+		if err != nil {
+			log.Errorf("collector run failed: %v", err)
+		}
+
+		finished := time.Now()
+		var earliest time.Time
+
+		for _, t := range targets {
+			if !next[t].After(now) {
+				delay := delays[t]
+				next[t] = nextRun(w.scheduleFor(t.client, t.tag), &delay, posts[t], finished)
+				delays[t] = delay
+			}
+
+			if earliest.IsZero() || next[t].Before(earliest) {
+				earliest = next[t]
+			}
+		}
+
+		if earliest.IsZero() {
+			<-ctx.Done()
+			return
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+
+		select {
+		case <-timer.C:
+			// proceed through next iteration
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
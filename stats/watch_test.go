@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivan3bx/proma/client"
+	"github.com/mattn/go-mastodon"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestStartWatches(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = map[string]int{}
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, "/api/v1/timelines/tag/")
+
+		mu.Lock()
+		hits[tag]++
+		n := hits[tag]
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"id":"%d","uri":"https://a.example/%s/%d","created_at":"2023-07-01T12:00:00Z","tags":[{"name":"%s"}]}]`,
+			n, tag, n, tag)
+	}))
+	defer ts.Close()
+
+	c := newTestCollector(t)
+	cl := client.NewClient(&mastodon.Config{Server: ts.URL})
+
+	c.StartWatches(context.Background(), []Watch{
+		{Name: "fast", Tags: []string{"outage"}, Clients: []*mastodon.Client{cl}, Schedule: Every(10 * time.Millisecond)},
+		{Name: "slow", Tags: []string{"flood"}, Clients: []*mastodon.Client{cl}, Schedule: Every(time.Hour)},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if hits["outage"] < 3 {
+		t.Errorf("expected fast watch to run several times, ran %d\n", hits["outage"])
+	}
+	if hits["flood"] != 1 {
+		t.Errorf("expected slow watch to run once (%v / %v)\n", 1, hits["flood"])
+	}
+
+	tags, err := c.TagSummaries(context.Background(), ReportOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags) != 2 || tags[0].Tag != "flood" || tags[1].Tag != "outage" {
+		t.Errorf("expected posts for both watches, was: %+v\n", tags)
+	}
+}
+
+func TestRunWatchWithoutTags(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	c := newTestCollector(t)
+	cl := client.NewClient(&mastodon.Config{Server: "https://mastodon.social"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// returns once canceled, without collecting
+	c.runWatch(ctx, Watch{Clients: []*mastodon.Client{cl}, Schedule: Every(time.Millisecond)})
+
+	for _, e := range hook.AllEntries() {
+		if strings.HasPrefix(e.Message, "stored ") {
+			t.Fatalf("expected no collector runs without tags, was: %s\n", e.Message)
+		}
+	}
+}
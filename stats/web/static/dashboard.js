@@ -0,0 +1,190 @@
+// Renders the proma dashboard from /dashboard.json, refreshing periodically
+// and whenever the server pushes a new post on /events.
+(function () {
+  "use strict";
+
+  const refreshInterval = 15000;
+  const eventDelay = 500;
+  let range = "24h";
+  let timer = null;
+
+  function el(tag, attrs, children) {
+    const node = document.createElement(tag);
+    Object.entries(attrs || {}).forEach(([k, v]) => node.setAttribute(k, v));
+    (children || []).forEach((c) => node.append(c));
+    return node;
+  }
+
+  // link only sets href to http(s) URLs, as the server does for post content
+  function link(url, attrs, children) {
+    const safe = typeof url === "string" && (url.startsWith("https://") || url.startsWith("http://"));
+    return el("a", safe ? { href: url, ...attrs } : attrs, children);
+  }
+
+  function svg(tag, attrs) {
+    const node = document.createElementNS("http://www.w3.org/2000/svg", tag);
+    Object.entries(attrs || {}).forEach(([k, v]) => node.setAttribute(k, v));
+    return node;
+  }
+
+  function formatTime(value, interval) {
+    const d = new Date(value);
+    if (interval === "day") {
+      return d.toLocaleDateString();
+    }
+    return d.toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+  }
+
+  function renderChart(trend, interval) {
+    const width = 600, height = 120, pad = 16;
+    const buckets = trend.buckets || [];
+    const max = Math.max(1, ...buckets.map((b) => b.count));
+    const step = buckets.length > 1 ? (width - pad * 2) / (buckets.length - 1) : 0;
+
+    const x = (i) => pad + i * step;
+    const y = (n) => height - pad - (n / max) * (height - pad * 2);
+
+    const chart = svg("svg", { viewBox: `0 0 ${width} ${height}`, preserveAspectRatio: "none" });
+    const points = buckets.map((b, i) => `${x(i)},${y(b.count)}`);
+
+    if (points.length > 0) {
+      chart.append(svg("polygon", {
+        class: "area",
+        points: [`${x(0)},${y(0)}`, ...points, `${x(buckets.length - 1)},${y(0)}`].join(" "),
+      }));
+      chart.append(svg("polyline", { class: "line", points: points.join(" ") }));
+    }
+
+    buckets.forEach((b, i) => {
+      if (b.spike) {
+        const dot = svg("circle", { class: "spike", cx: x(i), cy: y(b.count), r: 4 });
+        const title = svg("title");
+        title.textContent = `${b.count} posts (z=${b.z_score.toFixed(1)})`;
+        dot.append(title);
+        chart.append(dot);
+      }
+    });
+
+    const maxLabel = svg("text", { class: "axis", x: 2, y: 10 });
+    maxLabel.textContent = max;
+    chart.append(maxLabel);
+
+    if (buckets.length > 0) {
+      const first = svg("text", { class: "axis", x: pad, y: height - 2 });
+      first.textContent = formatTime(buckets[0].start, interval);
+      const last = svg("text", { class: "axis", x: width - pad, y: height - 2, "text-anchor": "end" });
+      last.textContent = formatTime(buckets[buckets.length - 1].start, interval);
+      chart.append(first, last);
+    }
+
+    return el("div", { class: "chart" }, [
+      el("h3", {}, [`#${trend.tag} — ${trend.total} posts`]),
+      chart,
+    ]);
+  }
+
+  function renderPost(post) {
+    const content = el("div", { class: "content" });
+    // html is sanitized by the server
+    content.innerHTML = post.html;
+
+    const postLink = link(post.uri, { target: "_blank", rel: "noopener noreferrer" }, [
+      new Date(post.created_at).toLocaleString(),
+    ]);
+
+    const tags = (post.tag_list || []).filter((t) => t).map((t) => `#${t}`).join(" ");
+
+    return el("li", {}, [
+      el("div", { class: "meta" }, [postLink, ` · ${post.lang} · ${tags}`]),
+      content,
+    ]);
+  }
+
+  function renderServer(server, limits) {
+    const host = new URL(server.server).host;
+    const budget = limits.find((l) => l.host === host);
+    const lastSuccess = server.last_success && !server.last_success.startsWith("0001")
+      ? new Date(server.last_success).toLocaleTimeString()
+      : "never";
+
+    const failures = el("td", server.consecutive_failures > 0 ? { class: "failing", title: server.last_error } : {}, [
+      `${server.failures}/${server.runs}`,
+    ]);
+
+    return el("tr", {}, [
+      el("td", {}, [host]),
+      el("td", {}, [String(server.posts)]),
+      failures,
+      el("td", {}, [lastSuccess]),
+      el("td", {}, [budget ? `${budget.remaining}/${budget.limit}` : "–"]),
+    ]);
+  }
+
+  function renderPosters(posters) {
+    const byTag = new Map();
+    posters.forEach((p) => {
+      if (!byTag.has(p.tag)) {
+        byTag.set(p.tag, []);
+      }
+      byTag.get(p.tag).push(p);
+    });
+
+    return [...byTag].map(([tag, accounts]) => el("div", {}, [
+      el("h3", {}, [`#${tag}`]),
+      el("ol", {}, accounts.map((a) => el("li", {}, [
+        link(a.url, { target: "_blank", rel: "noopener noreferrer", title: a.acct }, [a.display_name || a.username]),
+        ` (${a.count})${a.bot ? " · bot" : ""}`,
+      ]))),
+    ]));
+  }
+
+  function render(data) {
+    document.getElementById("charts").replaceChildren(
+      ...(data.trends || []).map((t) => renderChart(t, data.interval)));
+
+    document.getElementById("posts").replaceChildren(...data.posts.map(renderPost));
+
+    document.getElementById("server-rows").replaceChildren(
+      ...data.servers.map((s) => renderServer(s, data.rate_limits || [])));
+
+    document.getElementById("cooccurring-tags").replaceChildren(
+      ...data.cooccurring.map((t) => el("li", {}, [`#${t.tag} (${t.count})`])));
+
+    document.getElementById("poster-lists").replaceChildren(...renderPosters(data.posters || []));
+
+    document.getElementById("updated").textContent =
+      `updated ${new Date(data.updated_at).toLocaleTimeString()}`;
+  }
+
+  function refresh() {
+    clearTimeout(timer);
+
+    fetch(`/dashboard.json?range=${encodeURIComponent(range)}`)
+      .then((resp) => resp.json())
+      .then(render)
+      .catch((err) => {
+        document.getElementById("updated").textContent = `update failed: ${err}`;
+      })
+      .finally(() => {
+        timer = setTimeout(refresh, refreshInterval);
+      });
+  }
+
+  document.querySelectorAll("#ranges button").forEach((button) => {
+    button.addEventListener("click", () => {
+      range = button.dataset.range;
+      document.querySelectorAll("#ranges button").forEach((b) => b.classList.toggle("selected", b === button));
+      refresh();
+    });
+  });
+
+  if (window.EventSource) {
+    // batches posts arriving together into a single refresh
+    new EventSource("/events").addEventListener("post", () => {
+      clearTimeout(timer);
+      timer = setTimeout(refresh, eventDelay);
+    });
+  }
+
+  refresh();
+})();